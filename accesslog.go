@@ -0,0 +1,277 @@
+package goster
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Formats of the AccessLog middleware. Besides these, AccessLogOptions.Format can be any text with ${tags}:
+//
+//	${remote_ip}         the IP address of the client
+//	${user}              the user of the Basic Authorization header
+//	${time}              the time the request was received, in RFC 3339
+//	${time_clf}          the time the request was received, in the Common Log Format ("10/Oct/2000:13:55:36 -0700")
+//	${method}            the method of the request
+//	${uri}               the path and query of the request as it was sent
+//	${path}              the path of the request
+//	${query}             the query string of the request
+//	${proto}             the protocol of the request, like "HTTP/1.1"
+//	${host}              the host of the request
+//	${status}            the status code of the response
+//	${bytes}             the number of body bytes sent
+//	${latency}           the time it took to handle the request, like "1.52ms"
+//	${latency_ms}        the time it took to handle the request in milliseconds
+//	${request_id}        the ID given to the request by the RequestID middleware
+//	${header:Name}       the request header Name
+//	${resp_header:Name}  the response header Name
+//
+// Empty values are written as "-".
+const (
+	AccessLogCommon   = `${remote_ip} - ${user} [${time_clf}] "${method} ${uri} ${proto}" ${status} ${bytes}`
+	AccessLogCombined = AccessLogCommon + ` "${header:Referer}" "${header:User-Agent}"`
+	AccessLogJSON     = "json"
+)
+
+// clfTimeLayout is the layout of times in the Common Log Format
+const clfTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// AccessLogOptions configure the AccessLog middleware.
+type AccessLogOptions struct {
+	Format     string              // Format is AccessLogCommon, AccessLogCombined, AccessLogJSON or a template made of ${tags}. AccessLogCombined if empty
+	Output     io.Writer           // Output is where the lines are written, os.Stdout if nil
+	SampleRate float64             // SampleRate is the fraction of requests that are logged, e.g. 0.1 for one in ten. 0 logs every request. Responses with a status of 400 or more are always logged
+	SkipPaths  []string            // SkipPaths are the URL paths that are never logged, like "/healthz"
+	Skip       func(ctx *Ctx) bool // Skip reports whether a request shouldn't be logged. It's called once the response has been sent
+}
+
+// accessLogPart is either a literal piece of an access log format or the value of one of its tags
+type accessLogPart struct {
+	literal string
+	value   func(ctx *Ctx, status int, latency time.Duration) string
+}
+
+// accessLogger holds the configuration of an AccessLog middleware
+type accessLogger struct {
+	parts      []accessLogPart // parts of the format, nil for AccessLogJSON
+	out        io.Writer
+	mu         sync.Mutex // mu makes sure lines of concurrent requests aren't interleaved
+	sampleRate float64
+	skipPaths  map[string]struct{}
+	skip       func(ctx *Ctx) bool
+}
+
+// AccessLog returns a middleware that writes a line for every request once its response has been sent, with the status,
+// size and duration of the response:
+//
+//	g.UseGlobal(goster.AccessLog())
+//	g.UseGlobal(goster.AccessLog(goster.AccessLogOptions{
+//		Format:    "${status} ${latency} ${header:User-Agent}",
+//		SkipPaths: []string{"/healthz"},
+//	}))
+//
+// Register it before any other middleware, so that the time spent in them is included. AccessLog panics if the
+// format contains an unknown tag.
+func AccessLog(opts ...AccessLogOptions) RequestHandler {
+	var options AccessLogOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+
+	l := &accessLogger{out: options.Output, sampleRate: options.SampleRate, skip: options.Skip}
+	if l.out == nil {
+		l.out = os.Stdout
+	}
+	if options.Format == "" {
+		options.Format = AccessLogCombined
+	}
+	if options.Format != AccessLogJSON {
+		parts, err := parseAccessLogFormat(options.Format)
+		if err != nil {
+			panic("goster: " + err.Error())
+		}
+		l.parts = parts
+	}
+	if len(options.SkipPaths) > 0 {
+		l.skipPaths = make(map[string]struct{}, len(options.SkipPaths))
+		for _, p := range options.SkipPaths {
+			cleanPath(&p)
+			l.skipPaths[p] = struct{}{}
+		}
+	}
+
+	return func(ctx *Ctx) error {
+		if l.skipPaths != nil {
+			p := ctx.Request.URL.Path
+			cleanPath(&p)
+			if _, skip := l.skipPaths[p]; skip {
+				return nil
+			}
+		}
+
+		ctx.Next()
+
+		status := ctx.Response.Status()
+		if status == 0 {
+			// nothing has been sent yet, net/http sends a 200 once the handler returns
+			status = http.StatusOK
+		}
+		if l.skip != nil && l.skip(ctx) {
+			return nil
+		}
+		if l.sampleRate > 0 && l.sampleRate < 1 && status < http.StatusBadRequest && rand.Float64() >= l.sampleRate {
+			return nil
+		}
+
+		l.write(ctx, status, ctx.Response.Elapsed())
+		return nil
+	}
+}
+
+// write writes the line of the request to the output of l
+func (l *accessLogger) write(ctx *Ctx, status int, latency time.Duration) {
+	var line []byte
+	if l.parts == nil {
+		line = accessLogJSON(ctx, status, latency)
+	} else {
+		line = make([]byte, 0, 256)
+		for _, part := range l.parts {
+			if part.value == nil {
+				line = append(line, part.literal...)
+				continue
+			}
+
+			v := part.value(ctx, status, latency)
+			if v == "" {
+				v = "-"
+			}
+			line = append(line, v...)
+		}
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out.Write(line)
+}
+
+// accessLogEntry is a line of the AccessLogJSON format
+type accessLogEntry struct {
+	Time      string  `json:"time"`
+	RemoteIP  string  `json:"remote_ip"`
+	Method    string  `json:"method"`
+	URI       string  `json:"uri"`
+	Proto     string  `json:"proto"`
+	Host      string  `json:"host"`
+	Status    int     `json:"status"`
+	Bytes     int64   `json:"bytes"`
+	LatencyMS float64 `json:"latency_ms"`
+	Referer   string  `json:"referer,omitempty"`
+	UserAgent string  `json:"user_agent,omitempty"`
+	RequestID string  `json:"request_id,omitempty"`
+}
+
+func accessLogJSON(ctx *Ctx, status int, latency time.Duration) []byte {
+	b, _ := json.Marshal(accessLogEntry{
+		Time:      ctx.Response.start.Format(time.RFC3339),
+		RemoteIP:  remoteIP(ctx.Request),
+		Method:    ctx.Request.Method,
+		URI:       ctx.Request.RequestURI,
+		Proto:     ctx.Request.Proto,
+		Host:      ctx.Request.Host,
+		Status:    status,
+		Bytes:     ctx.Response.Written(),
+		LatencyMS: float64(latency.Microseconds()) / 1000,
+		Referer:   ctx.Request.Referer(),
+		UserAgent: ctx.Request.UserAgent(),
+		RequestID: ctx.RequestID(),
+	})
+
+	return b
+}
+
+// accessLogTags are the values of the tags an access log format can contain, except for the header ones
+var accessLogTags = map[string]func(ctx *Ctx, status int, latency time.Duration) string{
+	"remote_ip": func(ctx *Ctx, _ int, _ time.Duration) string { return remoteIP(ctx.Request) },
+	"user": func(ctx *Ctx, _ int, _ time.Duration) string {
+		user, _, _ := ctx.Request.BasicAuth()
+		return user
+	},
+	"time":     func(ctx *Ctx, _ int, _ time.Duration) string { return ctx.Response.start.Format(time.RFC3339) },
+	"time_clf": func(ctx *Ctx, _ int, _ time.Duration) string { return ctx.Response.start.Format(clfTimeLayout) },
+	"method":   func(ctx *Ctx, _ int, _ time.Duration) string { return ctx.Request.Method },
+	"uri":      func(ctx *Ctx, _ int, _ time.Duration) string { return ctx.Request.RequestURI },
+	"path":     func(ctx *Ctx, _ int, _ time.Duration) string { return ctx.Request.URL.Path },
+	"query":    func(ctx *Ctx, _ int, _ time.Duration) string { return ctx.Request.URL.RawQuery },
+	"proto":    func(ctx *Ctx, _ int, _ time.Duration) string { return ctx.Request.Proto },
+	"host":     func(ctx *Ctx, _ int, _ time.Duration) string { return ctx.Request.Host },
+	"status":   func(_ *Ctx, status int, _ time.Duration) string { return strconv.Itoa(status) },
+	"bytes": func(ctx *Ctx, _ int, _ time.Duration) string {
+		return strconv.FormatInt(ctx.Response.Written(), 10)
+	},
+	"latency": func(_ *Ctx, _ int, latency time.Duration) string { return latency.String() },
+	"latency_ms": func(_ *Ctx, _ int, latency time.Duration) string {
+		return strconv.FormatFloat(float64(latency.Microseconds())/1000, 'f', 3, 64)
+	},
+	"request_id": func(ctx *Ctx, _ int, _ time.Duration) string { return ctx.RequestID() },
+}
+
+// parseAccessLogFormat splits format into its literal pieces and tags. An error is returned for unknown or unterminated tags.
+func parseAccessLogFormat(format string) (parts []accessLogPart, err error) {
+	for format != "" {
+		start := strings.Index(format, "${")
+		if start == -1 {
+			parts = append(parts, accessLogPart{literal: format})
+			break
+		}
+		if start > 0 {
+			parts = append(parts, accessLogPart{literal: format[:start]})
+		}
+
+		end := strings.IndexByte(format[start:], '}')
+		if end == -1 {
+			return nil, fmt.Errorf("unterminated tag in access log format `%s`", format[start:])
+		}
+		tag := format[start+2 : start+end]
+		format = format[start+end+1:]
+
+		if name, found := strings.CutPrefix(tag, "header:"); found {
+			parts = append(parts, accessLogPart{value: func(ctx *Ctx, _ int, _ time.Duration) string {
+				return ctx.Request.Header.Get(name)
+			}})
+			continue
+		}
+		if name, found := strings.CutPrefix(tag, "resp_header:"); found {
+			parts = append(parts, accessLogPart{value: func(ctx *Ctx, _ int, _ time.Duration) string {
+				return ctx.Response.Header().Get(name)
+			}})
+			continue
+		}
+
+		value, exists := accessLogTags[tag]
+		if !exists {
+			return nil, fmt.Errorf("unknown access log tag `${%s}`", tag)
+		}
+		parts = append(parts, accessLogPart{value: value})
+	}
+
+	return
+}
+
+// remoteIP returns the IP address of the client that sent r, without the port
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,125 @@
+package goster
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+type AccessLogCase struct {
+	name     string
+	opts     AccessLogOptions
+	url      string
+	status   int
+	expected string // expected is a regular expression the line has to match, empty if nothing should be logged
+}
+
+func TestAccessLog(t *testing.T) {
+	g := NewServer()
+
+	testCases := []AccessLogCase{
+		{"Common", AccessLogOptions{Format: AccessLogCommon}, "/access/common?page=2", 200,
+			`^192\.0\.2\.1 - alice \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "GET /access/common\?page=2 HTTP/1\.1" 200 5$`},
+		{"Combined", AccessLogOptions{}, "/access/combined", 200,
+			`^192\.0\.2\.1 - alice \[.+\] "GET /access/combined HTTP/1\.1" 200 5 "https://example\.com/" "goster-test"$`},
+		{"Custom", AccessLogOptions{Format: "${status} ${latency} ${header:User-Agent} ${resp_header:X-Custom} ${header:X-Missing}"}, "/access/custom", 201,
+			`^201 \S+s goster-test yes -$`},
+		{"Skip path", AccessLogOptions{SkipPaths: []string{"/access/skip-path/"}}, "/access/skip-path", 200, ""},
+		{"Skip func", AccessLogOptions{Skip: func(ctx *Ctx) bool { return ctx.Response.Status() == http.StatusOK }}, "/access/skip-func", 200, ""},
+		{"Not skipped by func", AccessLogOptions{Format: "${status}", Skip: func(ctx *Ctx) bool { return ctx.Response.Status() == http.StatusOK }}, "/access/keep-func", 202, `^202$`},
+		{"Sampled out", AccessLogOptions{SampleRate: 1e-12}, "/access/sampled", 200, ""},
+		{"Errors not sampled", AccessLogOptions{Format: "${status} ${path}", SampleRate: 1e-12}, "/access/sampled-error", 500, `^500 /access/sampled-error$`},
+	}
+
+	failedCases := make(map[int]AccessLogCase, 0)
+	for i, c := range testCases {
+		var buf bytes.Buffer
+		c.opts.Output = &buf
+
+		route := strings.SplitN(c.url, "?", 2)[0]
+		_ = g.Get(route, func(ctx *Ctx) error {
+			ctx.Response.Header().Set("X-Custom", "yes")
+			ctx.Response.WriteHeader(c.status)
+			ctx.Response.Write([]byte("hello"))
+			return nil
+		})
+		g.Use(route, AccessLog(c.opts))
+
+		req := httptest.NewRequest(http.MethodGet, c.url, nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.SetBasicAuth("alice", "secret")
+		req.Header.Set("Referer", "https://example.com/")
+		req.Header.Set("User-Agent", "goster-test")
+		g.ServeHTTP(httptest.NewRecorder(), req)
+
+		line := strings.TrimSuffix(buf.String(), "\n")
+		failed := false
+		if c.expected == "" {
+			failed = buf.Len() != 0
+		} else {
+			failed = strings.Count(buf.String(), "\n") != 1 || !regexp.MustCompile(c.expected).MatchString(line)
+		}
+
+		if failed {
+			failedCases[i] = c
+			t.Errorf("FAILED [%d] - %s: got %q", i, c.name, buf.String())
+		} else {
+			t.Logf("PASSED [%d] - %s\n", i, c.name)
+		}
+	}
+
+	t.Logf("TOTAL CASES: %d\n", len(testCases))
+	t.Logf("FAILED CASES: %d\n", len(failedCases))
+}
+
+func TestAccessLogJSON(t *testing.T) {
+	g := NewServer()
+
+	var buf bytes.Buffer
+	_ = g.Get("/access-json", func(ctx *Ctx) error {
+		return errors.New("boom")
+	})
+	g.Use("/access-json", AccessLog(AccessLogOptions{Format: AccessLogJSON, Output: &buf}))
+
+	req := httptest.NewRequest(http.MethodGet, "/access-json?x=1", nil)
+	req.Header.Set("User-Agent", "goster-test")
+	g.ServeHTTP(httptest.NewRecorder(), req)
+
+	entry := map[string]any{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("expected a JSON line, got %q", buf.String())
+	}
+	if entry["method"] != "GET" || entry["uri"] != "/access-json?x=1" || entry["status"] != float64(500) ||
+		entry["user_agent"] != "goster-test" || entry["latency_ms"] == nil || entry["time"] == nil {
+		t.Errorf("unexpected access log entry %v", entry)
+	}
+}
+
+func TestAccessLogUnmatched(t *testing.T) {
+	g := NewServer()
+	global := g.Middleware["*"]
+	defer func() { g.Middleware["*"] = global }()
+
+	var buf bytes.Buffer
+	g.UseGlobal(AccessLog(AccessLogOptions{Format: "${method} ${path} ${status}", Output: &buf}))
+
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/access-unmatched/nothing-here", nil))
+
+	if rec.Code != http.StatusNotFound || buf.String() != "GET /access-unmatched/nothing-here 404\n" {
+		t.Errorf("expected the 404 to be logged, got %d and %q", rec.Code, buf.String())
+	}
+}
+
+func TestAccessLogFormatErrors(t *testing.T) {
+	for _, format := range []string{"${status", "${unknown}"} {
+		if _, err := parseAccessLogFormat(format); err == nil {
+			t.Errorf("expected an error for format %q", format)
+		}
+	}
+}
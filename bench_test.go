@@ -0,0 +1,67 @@
+package goster
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// benchWriter is an http.ResponseWriter that discards everything written to it.
+// It reuses its header map between requests like a connection of net/http would reuse its buffers.
+type benchWriter struct {
+	header http.Header
+}
+
+func (w *benchWriter) Header() http.Header               { return w.header }
+func (w *benchWriter) Write(b []byte) (int, error)       { return len(b), nil }
+func (w *benchWriter) WriteString(s string) (int, error) { return len(s), nil }
+func (w *benchWriter) WriteHeader(int)                   {}
+
+func benchmarkRequest(b *testing.B, register func(g *Goster), target string) {
+	g := NewServer()
+	logger := g.Logger
+	// request logs are at the info level, so they're never formatted
+	g.Logger = NewLogger(io.Discard, LogFormatText, slog.LevelError)
+	defer func() { g.Logger = logger }()
+	register(g)
+
+	w := &benchWriter{header: make(http.Header)}
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g.ServeHTTP(w, r)
+	}
+}
+
+func BenchmarkStaticRoute(b *testing.B) {
+	benchmarkRequest(b, func(g *Goster) {
+		_ = g.Get("/bench/static", func(ctx *Ctx) error {
+			ctx.Text("Hello, World!")
+			return nil
+		})
+	}, "/bench/static")
+}
+
+func BenchmarkParamRoute(b *testing.B) {
+	benchmarkRequest(b, func(g *Goster) {
+		_ = g.Get("/bench/users/:id/posts/:post", func(ctx *Ctx) error {
+			id, _ := ctx.Path.Get("id")
+			ctx.Text(id)
+			return nil
+		})
+	}, "/bench/users/42/posts/7")
+}
+
+func BenchmarkQueryRoute(b *testing.B) {
+	benchmarkRequest(b, func(g *Goster) {
+		_ = g.Get("/bench/search", func(ctx *Ctx) error {
+			q, _ := ctx.Query.Get("q")
+			ctx.Text(q)
+			return nil
+		})
+	}, "/bench/search?q=goster&page=2&sort=desc")
+}
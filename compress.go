@@ -0,0 +1,279 @@
+package goster
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+)
+
+// CompressOptions configure the Compress middleware.
+type CompressOptions struct {
+	Level     int      // Level is the compression level, from gzip.BestSpeed to gzip.BestCompression. 0 (or an invalid level) means gzip.DefaultCompression
+	MinSize   int      // MinSize is the size a body needs to reach to be compressed, 1024 bytes if 0
+	MimeTypes []string // MimeTypes are the content types that are compressed, as path.Match patterns like "text/*". DefaultCompressTypes if empty
+}
+
+// DefaultCompressTypes are the content types Compress compresses by default. Content types that are already
+// compressed, like images, videos and archives, are left out since compressing them again only wastes CPU.
+var DefaultCompressTypes = []string{
+	"text/*",
+	"application/json",
+	"application/*+json",
+	"application/javascript",
+	"application/xml",
+	"application/*+xml",
+	"application/wasm",
+	"image/svg+xml",
+}
+
+// compressWriterResetter is implemented by both *gzip.Writer and *flate.Writer, so that they can be pooled and reused
+type compressWriterResetter interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// compressor holds the configuration of a Compress middleware along with its pooled writers
+type compressor struct {
+	level     int
+	minSize   int
+	mimeTypes []string
+	gzipPool  sync.Pool
+	flatePool sync.Pool
+}
+
+// Compress returns a middleware that compresses responses with gzip or deflate, whichever the Accept-Encoding header
+// of the request prefers. Bodies smaller than CompressOptions.MinSize, content types that aren't allowed, responses
+// that already have a Content-Encoding (like precompressed static files) and partial content aren't compressed.
+//
+//	g.UseGlobal(goster.Compress())
+//	g.UseGlobal(goster.Compress(goster.CompressOptions{Level: gzip.BestSpeed, MimeTypes: []string{"text/html", "application/json"}}))
+//
+// Compressed responses don't have a Content-Length and their ETag, if any, is made weak. Flushing (e.g. for streaming) is supported.
+// Since compression happens as the response is written, Compress should be registered before middleware that writes responses.
+func Compress(opts ...CompressOptions) RequestHandler {
+	var options CompressOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+
+	c := &compressor{level: options.Level, minSize: options.MinSize, mimeTypes: options.MimeTypes}
+	if c.level == 0 || c.level < gzip.HuffmanOnly || c.level > gzip.BestCompression {
+		c.level = gzip.DefaultCompression
+	}
+	if c.minSize <= 0 {
+		c.minSize = 1024
+	}
+	if len(c.mimeTypes) == 0 {
+		c.mimeTypes = DefaultCompressTypes
+	}
+
+	return func(ctx *Ctx) error {
+		addVary(ctx.Response.Header(), "Accept-Encoding")
+
+		coding := c.negotiate(ctx.Request.Header.Get("Accept-Encoding"))
+		if coding == "" || ctx.Request.Method == http.MethodHead {
+			return nil
+		}
+
+		cw := &compressWriter{ResponseWriter: ctx.Response.ResponseWriter, c: c, coding: coding}
+		ctx.Response.ResponseWriter = cw
+		defer func() {
+			ctx.Response.ResponseWriter = cw.ResponseWriter
+		}()
+
+		ctx.Next()
+		return cw.finish()
+	}
+}
+
+// negotiate returns the content coding that the Accept-Encoding header h prefers, gzip winning ties.
+// If neither gzip nor deflate is accepted an empty string is returned.
+func (c *compressor) negotiate(h string) (coding string) {
+	if h == "" {
+		return
+	}
+
+	best := 0.0
+	for _, candidate := range []string{"gzip", "deflate"} {
+		if q := acceptEncodingQuality(h, candidate); q > best {
+			coding, best = candidate, q
+		}
+	}
+
+	return
+}
+
+// compresses reports whether c compresses content of the type contentType
+func (c *compressor) compresses(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	for _, pattern := range c.mimeTypes {
+		if matched, _ := path.Match(pattern, mediaType); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writer returns a pooled writer for coding that writes to w
+func (c *compressor) writer(coding string, w io.Writer) compressWriterResetter {
+	pool := &c.gzipPool
+	if coding == "deflate" {
+		pool = &c.flatePool
+	}
+
+	if cw, ok := pool.Get().(compressWriterResetter); ok {
+		cw.Reset(w)
+		return cw
+	}
+
+	// the level is validated by Compress, so creating the writers can't fail
+	if coding == "deflate" {
+		fw, _ := flate.NewWriter(w, c.level)
+		return fw
+	}
+	gw, _ := gzip.NewWriterLevel(w, c.level)
+	return gw
+}
+
+// release puts the writer for coding back in its pool
+func (c *compressor) release(coding string, w compressWriterResetter) {
+	if coding == "deflate" {
+		c.flatePool.Put(w)
+	} else {
+		c.gzipPool.Put(w)
+	}
+}
+
+// compressWriter compresses what's written to it before passing it to the wrapped writer. The body is buffered until
+// it reaches the minimum size (or the handler flushes or returns), at which point it decides whether to compress it.
+type compressWriter struct {
+	http.ResponseWriter
+	c       *compressor
+	coding  string                 // coding is the content coding negotiated with the client
+	status  int                    // status is the status code held back until the decision is made
+	buf     []byte                 // buf holds the body written before the decision is made
+	decided bool                   // decided reports whether it has been decided to compress the body or not
+	w       compressWriterResetter // w compresses the body, nil if it isn't compressed
+}
+
+func (cw *compressWriter) WriteHeader(s int) {
+	// informational responses (like 103 Early Hints) don't have a body and can be sent right away
+	if cw.decided || s < http.StatusOK {
+		cw.ResponseWriter.WriteHeader(s)
+		return
+	}
+
+	if cw.status == 0 {
+		cw.status = s
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if cw.w != nil {
+		return cw.w.Write(b)
+	}
+	if cw.decided {
+		return cw.ResponseWriter.Write(b)
+	}
+
+	cw.buf = append(cw.buf, b...)
+	if len(cw.buf) >= cw.c.minSize {
+		if err := cw.decide(); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(b), nil
+}
+
+// decide decides whether to compress the body, sends the header and writes the buffered body.
+func (cw *compressWriter) decide() (err error) {
+	cw.decided = true
+
+	h := cw.Header()
+	if h.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		// net/http would sniff the compressed body otherwise
+		h.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+
+	if cw.shouldCompress() {
+		h.Del("Content-Length")
+		h.Del("Accept-Ranges")
+		h.Set("Content-Encoding", cw.coding)
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+		cw.w = cw.c.writer(cw.coding, cw.ResponseWriter)
+	}
+
+	if cw.status != 0 {
+		cw.ResponseWriter.WriteHeader(cw.status)
+	}
+
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) > 0 {
+		_, err = cw.Write(buf)
+	}
+
+	return
+}
+
+// shouldCompress reports whether the buffered body is worth compressing
+func (cw *compressWriter) shouldCompress() bool {
+	switch cw.status {
+	case http.StatusNoContent, http.StatusPartialContent, http.StatusNotModified:
+		return false
+	}
+
+	h := cw.Header()
+	return len(cw.buf) >= cw.c.minSize && h.Get("Content-Encoding") == "" && cw.c.compresses(h.Get("Content-Type"))
+}
+
+// FlushError sends the data written so far to the client, compressed or not.
+func (cw *compressWriter) FlushError() error {
+	if !cw.decided {
+		if err := cw.decide(); err != nil {
+			return err
+		}
+	}
+	if cw.w != nil {
+		if err := cw.w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	return http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+func (cw *compressWriter) Flush() {
+	_ = cw.FlushError()
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// finish writes whatever is left of the body once the handler has returned and releases the compressor.
+func (cw *compressWriter) finish() (err error) {
+	if !cw.decided {
+		err = cw.decide()
+	}
+
+	if cw.w != nil {
+		if closeErr := cw.w.Close(); err == nil {
+			err = closeErr
+		}
+		cw.c.release(cw.coding, cw.w)
+		cw.w = nil
+	}
+
+	return
+}
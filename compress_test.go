@@ -0,0 +1,143 @@
+package goster
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type CompressCase struct {
+	name             string
+	acceptEncoding   string
+	contentType      string
+	body             string
+	etag             string
+	expectedEncoding string
+	expectedETag     string
+}
+
+func TestCompress(t *testing.T) {
+	g := NewServer()
+	large := strings.Repeat("goster compresses responses ", 100)
+
+	testCases := []CompressCase{
+		{"Gzip", "gzip, deflate", "text/plain", large, "", "gzip", ""},
+		{"Deflate by quality", "gzip;q=0.5, deflate", "application/json", large, "", "deflate", ""},
+		{"Not accepted", "br", "text/plain", large, "", "", ""},
+		{"Without Accept-Encoding", "", "text/plain", large, "", "", ""},
+		{"Small body", "gzip", "text/plain", "small", "", "", ""},
+		{"Type not allowed", "gzip", "image/png", large, "", "", ""},
+		{"Sniffed type", "gzip", "", "<html><body>" + large + "</body></html>", "", "gzip", ""},
+		{"Weak ETag", "gzip", "text/css", large, `"abc"`, "gzip", `W/"abc"`},
+		{"Uncompressed ETag", "gzip", "text/css", "small", `"abc"`, "", `"abc"`},
+	}
+
+	failedCases := make(map[int]CompressCase, 0)
+	for i, c := range testCases {
+		route := "/compress/" + strings.ReplaceAll(strings.ToLower(c.name), " ", "-")
+		_ = g.Get(route, func(ctx *Ctx) error {
+			if c.contentType != "" {
+				ctx.Response.Header().Set("Content-Type", c.contentType)
+			}
+			if c.etag != "" {
+				ctx.Response.Header().Set("ETag", c.etag)
+			}
+			ctx.Response.Header().Set("Content-Length", "123")
+			_, err := ctx.Response.Write([]byte(c.body))
+			return err
+		})
+		g.Use(route, Compress())
+
+		req := httptest.NewRequest(http.MethodGet, route, nil)
+		if c.acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", c.acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		g.ServeHTTP(rec, req)
+
+		var body io.Reader = rec.Body
+		switch rec.Header().Get("Content-Encoding") {
+		case "gzip":
+			body, _ = gzip.NewReader(rec.Body)
+		case "deflate":
+			body = flate.NewReader(rec.Body)
+		}
+		decoded, _ := io.ReadAll(body)
+
+		h := rec.Header()
+		failed := string(decoded) != c.body || h.Get("Content-Encoding") != c.expectedEncoding || h.Get("Vary") != "Accept-Encoding"
+		if c.expectedEncoding != "" && h.Get("Content-Length") != "" {
+			failed = true
+		}
+		if c.expectedETag != "" && h.Get("ETag") != c.expectedETag {
+			failed = true
+		}
+
+		if failed {
+			failedCases[i] = c
+			t.Errorf("FAILED [%d] - %s: got %q with %v", i, c.name, decoded, h)
+		} else {
+			t.Logf("PASSED [%d] - %s\n", i, c.name)
+		}
+	}
+
+	t.Logf("TOTAL CASES: %d\n", len(testCases))
+	t.Logf("FAILED CASES: %d\n", len(failedCases))
+}
+
+func TestCompressStreaming(t *testing.T) {
+	g := NewServer()
+	chunk := strings.Repeat("event ", 200)
+	_ = g.Get("/compress-stream", func(ctx *Ctx) error {
+		ctx.Response.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; i < 3; i++ {
+			if _, err := ctx.Response.WriteString(chunk); err != nil {
+				return err
+			}
+			ctx.Response.Flush()
+		}
+		return nil
+	})
+	g.Use("/compress-stream", Compress(CompressOptions{Level: gzip.BestSpeed, MinSize: 100}))
+
+	req := httptest.NewRequest(http.MethodGet, "/compress-stream", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+
+	if !rec.Flushed || rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected a flushed gzip response, got %v", rec.Header())
+	}
+	gr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, _ := io.ReadAll(gr)
+	if string(decoded) != strings.Repeat(chunk, 3) {
+		t.Errorf("expected the decoded stream to contain all the chunks, got %d bytes", len(decoded))
+	}
+}
+
+func TestCompressPrecompressed(t *testing.T) {
+	g := NewServer()
+	_ = g.Get("/compress-precompressed", func(ctx *Ctx) error {
+		ctx.Response.Header().Set("Content-Type", "text/javascript")
+		ctx.Response.Header().Set("Content-Encoding", "br")
+		_, err := ctx.Response.WriteString(strings.Repeat("x", 2048))
+		return err
+	})
+	g.Use("/compress-precompressed", Compress())
+
+	req := httptest.NewRequest(http.MethodGet, "/compress-precompressed", nil)
+	req.Header.Set("Accept-Encoding", "gzip, br")
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+
+	if rec.Header().Get("Content-Encoding") != "br" || rec.Body.Len() != 2048 {
+		t.Errorf("expected the precompressed body to be sent as is, got %v and %d bytes", rec.Header(), rec.Body.Len())
+	}
+}
@@ -1,125 +1,249 @@
 package goster
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"html/template"
-	"io"
+	"log/slog"
+	"math"
 	"net/http"
-	"os"
-	"path/filepath"
-	"slices"
+	"sync"
 )
 
+// Ctx holds everything about the request that is being handled. Ctx objects are reused between requests,
+// so a Ctx must not be kept around (e.g. by a goroutine) after the handler has returned.
 type Ctx struct {
 	Request  *http.Request
 	Response Response
 	Meta
+	store    map[string]any   // store holds the values set with Ctx.Set during the request
+	handlers []RequestHandler // handlers is the chain of middleware and the route handler for the request
+	index    int              // index is the position of the handler currently running in handlers
+	g        *Goster          // g is the server handling the request
+	route    string           // route is the pattern of the route that matched the request
+
+	requestID string       // requestID is the ID given to the request by the RequestID middleware
+	span      *Span        // span is the span created for the request by the Tracing middleware
+	logger    *slog.Logger // logger is the logger returned by Ctx.Logger, created the first time it's needed
 }
 
-// Send an HTML template t file to the client. If template not in template dir then will return error.
-func (c *Ctx) Template(t string, data any) (err error) {
-	templatePaths := engine.Config.TemplatePaths
+// ctxPool keeps the Ctx of finished requests around so that they can be reused by new ones
+var ctxPool = sync.Pool{
+	New: func() any {
+		return new(Ctx)
+	},
+}
+
+// acquireCtx gets a Ctx from the pool and prepares it for the request r.
+func acquireCtx(g *Goster, w http.ResponseWriter, r *http.Request) *Ctx {
+	c := ctxPool.Get().(*Ctx)
+	c.Request = r
+	c.Response = newResponse(w)
+	c.g = g
+	return c
+}
 
-	// iterate through all known templates
-	for tmplId := range templatePaths {
-		// if given template matches a known template get the template path, parse it and write it to response
-		if tmplId == t {
-			tmpl := template.Must(template.ParseFiles(templatePaths[tmplId]))
-			err = tmpl.Execute(c.Response, data)
+// releaseCtx resets c and puts it back in the pool. c must not be used after it has been released.
+func releaseCtx(c *Ctx) {
+	c.Request = nil
+	c.Response = Response{}
+	c.Meta.Query.reset("")
+	c.Meta.Path = c.Meta.Path[:0]
+	clear(c.store)
+	clear(c.handlers)
+	c.handlers = c.handlers[:0]
+	c.index = 0
+	c.g = nil
+	c.route = ""
+	c.requestID = ""
+	c.span = nil
+	c.logger = nil
+	ctxPool.Put(c)
+}
 
-			if err != nil {
-				return err
-			}
+// abortIndex is the handler index a Ctx jumps to when the chain is aborted
+const abortIndex = math.MaxInt / 2
 
-		}
+// Set stores value under key for the rest of the request. It's meant for passing data from middleware
+// to handlers (e.g. the authenticated user).
+func (c *Ctx) Set(key string, value any) {
+	if c.store == nil {
+		c.store = make(map[string]any)
 	}
 
+	c.store[key] = value
+}
+
+// Get returns the value stored under key with Ctx.Set.
+//
+// If the specified `key` isn't found `exists` will be false
+func (c *Ctx) Get(key string) (value any, exists bool) {
+	value, exists = c.store[key]
 	return
 }
 
-// Send an HTML template t file to the client. TemplateWithFuncs supports functions to be embedded in the html template for use. If template not in template dir then will return error.
-func (c *Ctx) TemplateWithFuncs(t string, data any, funcMap template.FuncMap) (err error) {
-	templatePaths := engine.Config.TemplatePaths
+// MustGet returns the value stored under key with Ctx.Set and panics if there's none.
+func (c *Ctx) MustGet(key string) any {
+	value, exists := c.Get(key)
+	if !exists {
+		panic(fmt.Sprintf("goster: key `%s` does not exist in context", key))
+	}
+
+	return value
+}
+
+// GetAs returns the value stored under key in c as a T.
+//
+// If the specified `key` isn't found or the value isn't a T `exists` will be false
+func GetAs[T any](c *Ctx, key string) (value T, exists bool) {
+	v, exists := c.Get(key)
+	if !exists {
+		return
+	}
+
+	value, exists = v.(T)
+	return
+}
+
+// MustGetAs returns the value stored under key in c as a T and panics if there's none or it isn't a T.
+func MustGetAs[T any](c *Ctx, key string) T {
+	v := c.MustGet(key)
+	value, ok := v.(T)
+	if !ok {
+		panic(fmt.Sprintf("goster: value of key `%s` is %T, not %T", key, v, value))
+	}
+
+	return value
+}
 
-	// iterate through all known templates
-	for tmplId := range templatePaths {
-		// if given template matches a known template get the template path, parse it and write it to response
-		if tmplId == t {
-			tmplFile := templatePaths[tmplId]
-			baseFilename := filepath.Base(tmplId)
-			tmpl := template.Must(template.New(baseFilename).Funcs(funcMap).ParseFiles(tmplFile))
-			err = tmpl.Execute(c.Response, data)
+// Context returns the context.Context of the request. It's canceled when the client goes away
+// and carries any deadline or value that was added with Ctx.SetContext, so it's the one to pass to
+// database calls and outgoing requests.
+func (c *Ctx) Context() context.Context {
+	return c.Request.Context()
+}
 
-			if err != nil {
-				return err
-			}
+// SetContext replaces the context.Context of the request with ctx. Middleware can use it to add
+// deadlines or values that the following handlers will observe through Ctx.Context and Ctx.Request.Context.
+func (c *Ctx) SetContext(ctx context.Context) {
+	c.Request = c.Request.WithContext(ctx)
+}
 
+// Next runs the remaining handlers of the chain (middleware and the route handler) and returns once they are done.
+// Middleware can use it to run code after the handler, e.g. to release resources or inspect the response:
+//
+//	g.UseGlobal(func(ctx *goster.Ctx) error {
+//		c, cancel := context.WithTimeout(ctx.Context(), 5*time.Second)
+//		defer cancel()
+//		ctx.SetContext(c)
+//		ctx.Next()
+//		return nil
+//	})
+//
+// Middleware that doesn't call Next has the rest of the chain run after it returns.
+func (c *Ctx) Next() {
+	c.index++
+	for c.index < len(c.handlers) {
+		err := c.handlers[c.index](c)
+		if err != nil {
+			c.handleError(err)
 		}
+		c.index++
 	}
+}
 
-	return
+// Abort stops the chain so that none of the remaining handlers run. It doesn't stop the handler that called it.
+func (c *Ctx) Abort() {
+	c.index = abortIndex
+}
+
+// IsAborted reports whether the chain was stopped with Abort or by an error returned from middleware.
+func (c *Ctx) IsAborted() bool {
+	return c.index >= abortIndex
+}
+
+// handleError logs an error returned by the handler at the current index and passes it to the error handler of the server.
+// Errors returned from middleware abort the chain.
+func (c *Ctx) handleError(err error) {
+	if c.index < len(c.handlers)-1 {
+		LogError("error occured while running middleware", c.Logger(), "error", err, "method", c.Request.Method, "path", c.Request.URL.Path)
+		c.Abort()
+	} else {
+		LogError("error occured while handling request", c.Logger(), "error", err, "method", c.Request.Method, "path", c.Request.URL.Path)
+	}
+
+	c.g.errorHandler()(c, err)
+}
+
+// Send an HTML template t file to the client. If template not in template dir then will return error.
+func (c *Ctx) Template(t string, data any) (err error) {
+	return c.Render(t, data)
+}
+
+// Send an HTML template t file to the client. TemplateWithFuncs supports functions to be embedded in the html template for use. If template not in template dir then will return error.
+//
+// Unlike Template, the template is parsed on every call, since the functions have to be known when parsing.
+func (c *Ctx) TemplateWithFuncs(t string, data any, funcMap template.FuncMap) (err error) {
+	engine.reloadTemplates()
+	if _, exists := engine.templatePath(t); !exists {
+		return fmt.Errorf("%w: `%s`", ErrTemplateNotFound, t)
+	}
+
+	shared, err := engine.parseSharedTemplates(funcMap)
+	if err != nil {
+		return
+	}
+
+	tmpl, err := engine.parseTemplate(shared, t)
+	if err != nil {
+		return
+	}
+	if bound, err := c.withCSRFField(tmpl); err != nil {
+		return newTemplateError(t, err)
+	} else if bound != nil {
+		tmpl = bound
+	}
+
+	return c.execute(tmpl, t, data)
 }
 
-// Send an HTML f file to the client. If if file not in FilesDir dir then will return error.
+// Send an HTML f file to the client. If if file not in FilesDir dir then will return an error wrapping ErrTemplateNotFound.
 func (c *Ctx) HTML(t string) (err error) {
-	templatePaths := engine.Config.TemplatePaths
-
-	for tmplId := range templatePaths {
-		if tmplId == t {
-			// open template
-			file, err := os.Open(templatePaths[tmplId])
-			if err != nil {
-				fmt.Fprintln(os.Stderr, err)
-			}
-			defer file.Close()
-
-			// read file
-			fInfo, _ := file.Stat()
-			buf := make([]byte, fInfo.Size())
-			_, err = io.ReadFull(file, buf)
-			if err != nil {
-				return err
-			}
-
-			t := string(buf)
-
-			// set headers
-			contentType := getContentType(file.Name())
-			c.Response.Header().Set("Content-Type", contentType)
-
-			fmt.Fprint(c.Response.ResponseWriter, t) // write response
-		}
+	engine.reloadTemplates()
+	path, exists := engine.templatePath(t)
+	if !exists {
+		return fmt.Errorf("%w: `%s`", ErrTemplateNotFound, t)
+	}
+
+	content, err := engine.readTemplate(path)
+	if err != nil {
+		return
 	}
+
+	// set headers
+	contentType := getContentType(path)
+	c.Response.Header().Set("Content-Type", contentType)
+
+	_, err = c.Response.Write(content) // write response
 	return
 }
 
 // Send plain text to the client
+//
+// The Content-Length is left to net/http, which sets it for any response that fits in its buffer.
 func (c *Ctx) Text(s string) {
-	c.Response.Header().Set("Content-Length", fmt.Sprint(len(s)))
-	c.Response.Header().Set("Content-Type", "text/plain")
-	fmt.Fprint(c.Response.ResponseWriter, s)
+	c.Response.Header()["Content-Type"] = textPlainValue
+	_, _ = c.Response.WriteString(s)
 }
 
 // Send back a JSON response. Supply j with a value that's valid marsallable(?) to JSON -> error
+//
+// Values of type []byte and json.RawMessage are sent as they are, without any validation.
 func (c *Ctx) JSON(j any) (err error) {
-	if v, ok := j.([]byte); ok {
-		v = slices.DeleteFunc(v, func(b byte) bool {
-			return b == 0
-		})
-		c.Response.Header().Set("Content-Type", "application/json")
-		_, err = c.Response.Write(v)
-		return
-	}
-
-	v, err := json.Marshal(j)
-
+	err = c.encode(JSONEncoder{}, "application/json", 0, j)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err.Error())
-		return err
+		LogError("could not encode JSON response", c.Logger(), "error", err)
 	}
 
-	c.Response.Header().Set("Content-Type", "application/json")
-	_, err = c.Response.Write(v)
 	return
 }
@@ -0,0 +1,169 @@
+package goster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCtxStore(t *testing.T) {
+	ctx := Ctx{}
+
+	if _, exists := ctx.Get("user"); exists {
+		t.Errorf("expected `user` not to exist in an empty store")
+	}
+
+	ctx.Set("user", "dimitris")
+	ctx.Set("age", 24)
+
+	if v, exists := ctx.Get("user"); !exists || v != "dimitris" {
+		t.Errorf("expected `user` to be `dimitris`, got %v", v)
+	}
+	if v, exists := GetAs[int](&ctx, "age"); !exists || v != 24 {
+		t.Errorf("expected `age` to be 24, got %v", v)
+	}
+	if _, exists := GetAs[string](&ctx, "age"); exists {
+		t.Errorf("expected `age` not to be retrievable as a string")
+	}
+	if v := MustGetAs[string](&ctx, "user"); v != "dimitris" {
+		t.Errorf("expected `user` to be `dimitris`, got %v", v)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected MustGet to panic for a missing key")
+		}
+	}()
+	ctx.MustGet("missing")
+}
+
+func TestCtxChain(t *testing.T) {
+	g := NewServer()
+	order := []string{}
+	var deadline time.Time
+
+	g.Use("/ctx-chain", func(ctx *Ctx) error {
+		c, cancel := context.WithTimeout(ctx.Context(), time.Minute)
+		defer cancel()
+		ctx.SetContext(c)
+		ctx.Set("user", "dimitris")
+
+		order = append(order, "before")
+		ctx.Next()
+		order = append(order, "after")
+		return nil
+	}, func(ctx *Ctx) error {
+		order = append(order, "second")
+		return nil
+	})
+	_ = g.Get("/ctx-chain", func(ctx *Ctx) error {
+		order = append(order, "handler:"+MustGetAs[string](ctx, "user"))
+		deadline, _ = ctx.Request.Context().Deadline()
+		return nil
+	})
+
+	g.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ctx-chain", nil))
+
+	expected := []string{"before", "second", "handler:dimitris", "after"}
+	if len(order) != len(expected) {
+		t.Fatalf("expected chain %v, got %v", expected, order)
+	}
+	for i := range expected {
+		if order[i] != expected[i] {
+			t.Errorf("expected chain %v, got %v", expected, order)
+			break
+		}
+	}
+	if deadline.IsZero() {
+		t.Errorf("expected the deadline set by middleware to reach the handler")
+	}
+}
+
+func TestCtxChainAbort(t *testing.T) {
+	g := NewServer()
+	handled := false
+
+	g.Use("/ctx-abort", func(ctx *Ctx) error {
+		ctx.Response.WriteHeader(http.StatusUnauthorized)
+		return errors.New("unauthorized")
+	})
+	_ = g.Get("/ctx-abort", func(ctx *Ctx) error {
+		handled = true
+		return nil
+	})
+
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ctx-abort", nil))
+
+	if handled {
+		t.Errorf("expected the handler not to run after middleware returned an error")
+	}
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+}
+
+type MiddlewareErrorCase struct {
+	name     string
+	global   RequestHandler
+	route    []RequestHandler
+	handler  RequestHandler
+	expected string // expected are the handlers that ran, in order
+	status   int
+}
+
+func TestMiddlewareErrorStopsChain(t *testing.T) {
+	g := NewServer()
+	global := g.Middleware["*"]
+	defer func() { g.Middleware["*"] = global }()
+
+	var ran string
+	step := func(name string, err error) RequestHandler {
+		return func(ctx *Ctx) error {
+			ran += name
+			return err
+		}
+	}
+	abort := func(ctx *Ctx) error {
+		ran += "a"
+		ctx.Response.WriteHeader(http.StatusNoContent)
+		ctx.Abort()
+		return nil
+	}
+	failure := errors.New("failure")
+
+	testCases := []MiddlewareErrorCase{
+		{"No errors", step("g", nil), []RequestHandler{step("1", nil), step("2", nil)}, step("h", nil), "g12h", http.StatusOK},
+		{"Global middleware error", step("g", failure), []RequestHandler{step("1", nil)}, step("h", nil), "g", http.StatusInternalServerError},
+		{"Route middleware error", step("g", nil), []RequestHandler{step("1", failure), step("2", nil)}, step("h", nil), "g1", http.StatusInternalServerError},
+		{"Problem from middleware", step("g", nil), []RequestHandler{step("1", NewProblem(http.StatusUnauthorized, ""))}, step("h", nil), "g1", http.StatusUnauthorized},
+		{"Abort without error", step("g", nil), []RequestHandler{abort, step("2", nil)}, step("h", nil), "ga", http.StatusNoContent},
+		{"Handler error", step("g", nil), []RequestHandler{step("1", nil)}, step("h", failure), "g1h", http.StatusInternalServerError},
+	}
+
+	failedCases := make(map[int]MiddlewareErrorCase, 0)
+	for i, c := range testCases {
+		route := fmt.Sprintf("/middleware-error/%d", i)
+		_ = g.Get(route, c.handler)
+		g.Use(route, c.route...)
+		g.Middleware["*"] = []RequestHandler{c.global}
+		ran = ""
+
+		rec := httptest.NewRecorder()
+		g.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, route, nil))
+
+		if ran != c.expected || rec.Code != c.status {
+			failedCases[i] = c
+			t.Errorf("FAILED [%d] - %s: expected %q with %d, got %q with %d", i, c.name, c.expected, c.status, ran, rec.Code)
+		} else {
+			t.Logf("PASSED [%d] - %s\n", i, c.name)
+		}
+	}
+
+	t.Logf("TOTAL CASES: %d\n", len(testCases))
+	t.Logf("FAILED CASES: %d\n", len(failedCases))
+}
@@ -0,0 +1,210 @@
+package goster
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCORSMethods are the methods CORS allows when its options don't list any.
+var DefaultCORSMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// CORSOptions configure the CORS middleware.
+type CORSOptions struct {
+	// AllowOrigins are the origins allowed to make requests, like "https://example.com". An origin can contain
+	// a single "*" standing for any non-empty part, like "https://*.example.com", and "*" alone allows any origin.
+	// Any origin is allowed if neither AllowOrigins nor AllowOriginFunc are set.
+	AllowOrigins []string
+	// AllowOriginFunc allows origins that aren't listed in AllowOrigins, if it reports true for them.
+	AllowOriginFunc func(origin string) bool
+	// AllowMethods are the methods allowed in preflight requests, DefaultCORSMethods if empty.
+	AllowMethods []string
+	// AllowHeaders are the request headers allowed in preflight requests. If empty, the headers the preflight asks for are allowed.
+	AllowHeaders []string
+	// ExposeHeaders are the response headers, besides the CORS-safelisted ones, that scripts can read.
+	ExposeHeaders []string
+	// AllowCredentials lets requests carry cookies and authorization headers. It can't be used with the "*" origin.
+	AllowCredentials bool
+	// MaxAge is how long browsers can cache the result of a preflight request. Nothing is sent if it's 0, and a
+	// negative MaxAge disables caching.
+	MaxAge time.Duration
+}
+
+// corsPolicy is the precomputed form of CORSOptions
+type corsPolicy struct {
+	anyOrigin   bool
+	origins     map[string]struct{}
+	patterns    [][2]string // patterns are the prefixes and suffixes of the origins with a wildcard
+	originFunc  func(origin string) bool
+	methods     map[string]struct{}
+	credentials bool
+
+	// header values shared between all requests, they must never be modified in place
+	allowMethods  []string
+	allowHeaders  []string
+	exposeHeaders []string
+	maxAge        []string
+}
+
+var (
+	trueValue            = []string{"true"}
+	preflightVaryHeaders = []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"}
+)
+
+// CORS returns a middleware that implements Cross-Origin Resource Sharing, letting scripts on the allowed origins
+// make requests to the server:
+//
+//	g.UseGlobal(goster.CORS(goster.CORSOptions{
+//		AllowOrigins:     []string{"https://example.com", "https://*.example.com"},
+//		AllowCredentials: true,
+//		ExposeHeaders:    []string{"X-Request-ID"},
+//		MaxAge:           time.Hour,
+//	}))
+//
+// Preflight requests (OPTIONS with an Access-Control-Request-Method header) are answered with 204 No Content by the
+// middleware itself, so no OPTIONS routes are needed. Since they don't match any route, only global middleware sees them:
+// register CORS with UseGlobal for preflight requests to be handled.
+//
+// Requests from origins that aren't allowed are served without CORS headers, which makes browsers hide the response from scripts.
+//
+// It panics if AllowCredentials is used with the "*" origin, which browsers reject.
+func CORS(opts ...CORSOptions) RequestHandler {
+	var options CORSOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	if len(options.AllowOrigins) == 0 && options.AllowOriginFunc == nil {
+		options.AllowOrigins = []string{"*"}
+	}
+
+	p := &corsPolicy{origins: map[string]struct{}{}, methods: map[string]struct{}{}, originFunc: options.AllowOriginFunc, credentials: options.AllowCredentials}
+	for _, o := range options.AllowOrigins {
+		o = strings.ToLower(o)
+		if o == "*" {
+			p.anyOrigin = true
+		} else if prefix, suffix, found := strings.Cut(o, "*"); found {
+			p.patterns = append(p.patterns, [2]string{prefix, suffix})
+		} else {
+			p.origins[o] = struct{}{}
+		}
+	}
+	if p.anyOrigin && p.credentials {
+		panic(`goster: CORS can't allow credentials for any origin ("*"), list the allowed origins instead`)
+	}
+
+	methods := options.AllowMethods
+	if len(methods) == 0 {
+		methods = DefaultCORSMethods
+	}
+	for i, m := range methods {
+		m = strings.ToUpper(m)
+		p.methods[m] = struct{}{}
+		if i == 0 {
+			p.allowMethods = []string{m}
+		} else {
+			p.allowMethods[0] += ", " + m
+		}
+	}
+	if len(options.AllowHeaders) > 0 {
+		p.allowHeaders = []string{strings.Join(options.AllowHeaders, ", ")}
+	}
+	if len(options.ExposeHeaders) > 0 {
+		p.exposeHeaders = []string{strings.Join(options.ExposeHeaders, ", ")}
+	}
+	if options.MaxAge != 0 {
+		p.maxAge = []string{strconv.Itoa(max(int(options.MaxAge.Seconds()), 0))}
+	}
+
+	return func(ctx *Ctx) error {
+		if ctx.Request.Method == http.MethodOptions && ctx.Request.Header.Get("Access-Control-Request-Method") != "" {
+			p.preflight(ctx)
+			ctx.Abort()
+			return nil
+		}
+
+		p.actual(ctx)
+		return nil
+	}
+}
+
+// preflight answers the preflight request of ctx
+func (p *corsPolicy) preflight(ctx *Ctx) {
+	h := ctx.Response.Header()
+	for _, v := range preflightVaryHeaders {
+		addVary(h, v)
+	}
+	defer ctx.Response.WriteHeader(http.StatusNoContent)
+
+	origin := ctx.Request.Header.Get("Origin")
+	if !p.allowed(origin) {
+		return
+	}
+	if _, ok := p.methods[strings.ToUpper(ctx.Request.Header.Get("Access-Control-Request-Method"))]; !ok {
+		return
+	}
+
+	p.setOrigin(h, origin)
+	h["Access-Control-Allow-Methods"] = p.allowMethods
+	if p.allowHeaders != nil {
+		h["Access-Control-Allow-Headers"] = p.allowHeaders
+	} else if requested := ctx.Request.Header.Get("Access-Control-Request-Headers"); requested != "" {
+		h.Set("Access-Control-Allow-Headers", requested)
+	}
+	if p.maxAge != nil {
+		h["Access-Control-Max-Age"] = p.maxAge
+	}
+}
+
+// actual adds the CORS headers to the response of a request that isn't a preflight
+func (p *corsPolicy) actual(ctx *Ctx) {
+	h := ctx.Response.Header()
+	if !p.anyOrigin {
+		addVary(h, "Origin")
+	}
+
+	origin := ctx.Request.Header.Get("Origin")
+	if origin == "" || !p.allowed(origin) {
+		return
+	}
+
+	p.setOrigin(h, origin)
+	if p.exposeHeaders != nil {
+		h["Access-Control-Expose-Headers"] = p.exposeHeaders
+	}
+}
+
+// setOrigin sets the Access-Control-Allow-Origin header (and Access-Control-Allow-Credentials) of h for the allowed origin
+func (p *corsPolicy) setOrigin(h http.Header, origin string) {
+	if p.anyOrigin {
+		h["Access-Control-Allow-Origin"] = allowOriginValue
+		return
+	}
+
+	h.Set("Access-Control-Allow-Origin", origin)
+	if p.credentials {
+		h["Access-Control-Allow-Credentials"] = trueValue
+	}
+}
+
+// allowed reports whether requests from origin are allowed
+func (p *corsPolicy) allowed(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	if p.anyOrigin {
+		return true
+	}
+
+	o := strings.ToLower(origin)
+	if _, ok := p.origins[o]; ok {
+		return true
+	}
+	for _, pattern := range p.patterns {
+		if len(o) > len(pattern[0])+len(pattern[1]) && strings.HasPrefix(o, pattern[0]) && strings.HasSuffix(o, pattern[1]) {
+			return true
+		}
+	}
+
+	return p.originFunc != nil && p.originFunc(origin)
+}
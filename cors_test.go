@@ -0,0 +1,142 @@
+package goster
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type CORSCase struct {
+	name     string
+	opts     CORSOptions
+	method   string
+	headers  map[string]string
+	status   int
+	expected map[string]string // expected are the expected response headers, "" for headers that must be absent
+}
+
+func TestCORS(t *testing.T) {
+	g := NewServer()
+	global := g.Middleware["*"]
+	defer func() { g.Middleware["*"] = global }()
+
+	handled := 0
+	_ = g.Get("/cors-test/items", func(ctx *Ctx) error {
+		handled++
+		ctx.Text("items")
+		return nil
+	})
+
+	allowList := CORSOptions{
+		AllowOrigins:     []string{"https://example.com", "https://*.example.org"},
+		AllowHeaders:     []string{"Content-Type", "Authorization"},
+		ExposeHeaders:    []string{"X-Request-ID"},
+		AllowCredentials: true,
+		MaxAge:           time.Hour,
+	}
+
+	testCases := []CORSCase{
+		{"Any origin", CORSOptions{}, http.MethodGet, map[string]string{"Origin": "https://foo.com"}, http.StatusOK,
+			map[string]string{"Access-Control-Allow-Origin": "*", "Vary": "", "Access-Control-Allow-Credentials": ""}},
+		{"No origin", allowList, http.MethodGet, nil, http.StatusOK,
+			map[string]string{"Access-Control-Allow-Origin": "", "Vary": "Origin"}},
+		{"Allowed origin", allowList, http.MethodGet, map[string]string{"Origin": "https://example.com"}, http.StatusOK,
+			map[string]string{"Access-Control-Allow-Origin": "https://example.com", "Access-Control-Allow-Credentials": "true",
+				"Access-Control-Expose-Headers": "X-Request-ID", "Vary": "Origin", "Access-Control-Max-Age": ""}},
+		{"Allowed origin pattern", allowList, http.MethodGet, map[string]string{"Origin": "https://api.example.org"}, http.StatusOK,
+			map[string]string{"Access-Control-Allow-Origin": "https://api.example.org"}},
+		{"Pattern needs a subdomain", allowList, http.MethodGet, map[string]string{"Origin": "https://.example.org"}, http.StatusOK,
+			map[string]string{"Access-Control-Allow-Origin": ""}},
+		{"Disallowed origin", allowList, http.MethodGet, map[string]string{"Origin": "https://evil.com"}, http.StatusOK,
+			map[string]string{"Access-Control-Allow-Origin": "", "Access-Control-Allow-Credentials": "", "Vary": "Origin"}},
+		{"Origin func", CORSOptions{AllowOriginFunc: func(o string) bool { return strings.HasSuffix(o, ".test") }}, http.MethodGet,
+			map[string]string{"Origin": "http://app.test"}, http.StatusOK, map[string]string{"Access-Control-Allow-Origin": "http://app.test"}},
+		{"Preflight", allowList, http.MethodOptions,
+			map[string]string{"Origin": "https://example.com", "Access-Control-Request-Method": "PUT", "Access-Control-Request-Headers": "content-type"},
+			http.StatusNoContent,
+			map[string]string{"Access-Control-Allow-Origin": "https://example.com", "Access-Control-Allow-Methods": "GET, HEAD, POST, PUT, PATCH, DELETE",
+				"Access-Control-Allow-Headers": "Content-Type, Authorization", "Access-Control-Max-Age": "3600", "Access-Control-Allow-Credentials": "true",
+				"Vary": "Origin, Access-Control-Request-Method, Access-Control-Request-Headers"}},
+		{"Preflight reflects headers", CORSOptions{AllowMethods: []string{"get", "post"}}, http.MethodOptions,
+			map[string]string{"Origin": "https://foo.com", "Access-Control-Request-Method": "POST", "Access-Control-Request-Headers": "x-custom"},
+			http.StatusNoContent,
+			map[string]string{"Access-Control-Allow-Origin": "*", "Access-Control-Allow-Methods": "GET, POST", "Access-Control-Allow-Headers": "x-custom"}},
+		{"Preflight disallowed method", CORSOptions{AllowMethods: []string{"GET"}}, http.MethodOptions,
+			map[string]string{"Origin": "https://foo.com", "Access-Control-Request-Method": "DELETE"}, http.StatusNoContent,
+			map[string]string{"Access-Control-Allow-Origin": "", "Access-Control-Allow-Methods": ""}},
+		{"Preflight disallowed origin", allowList, http.MethodOptions,
+			map[string]string{"Origin": "https://evil.com", "Access-Control-Request-Method": "GET"}, http.StatusNoContent,
+			map[string]string{"Access-Control-Allow-Origin": "", "Access-Control-Allow-Methods": ""}},
+		{"Negative max age", CORSOptions{MaxAge: -1}, http.MethodOptions,
+			map[string]string{"Origin": "https://foo.com", "Access-Control-Request-Method": "GET"}, http.StatusNoContent,
+			map[string]string{"Access-Control-Max-Age": "0"}},
+		{"Plain OPTIONS isn't a preflight", CORSOptions{}, http.MethodOptions, map[string]string{"Origin": "https://foo.com"},
+			http.StatusMethodNotAllowed, map[string]string{"Access-Control-Allow-Origin": "*"}},
+	}
+
+	failedCases := make(map[int]CORSCase, 0)
+	for i, c := range testCases {
+		g.Middleware["*"] = []RequestHandler{CORS(c.opts)}
+		handled = 0
+
+		req := httptest.NewRequest(c.method, "/cors-test/items", nil)
+		for k, v := range c.headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		g.ServeHTTP(rec, req)
+
+		failed := rec.Code != c.status
+		for k, v := range c.expected {
+			failed = failed || strings.Join(rec.Header().Values(k), ", ") != v
+		}
+		if c.method == http.MethodGet {
+			failed = failed || handled != 1
+		} else {
+			failed = failed || handled != 0
+		}
+
+		if failed {
+			failedCases[i] = c
+			t.Errorf("FAILED [%d] - %s: expected %d with %v, got %d with %v", i, c.name, c.status, c.expected, rec.Code, rec.Header())
+		} else {
+			t.Logf("PASSED [%d] - %s\n", i, c.name)
+		}
+	}
+
+	t.Logf("TOTAL CASES: %d\n", len(testCases))
+	t.Logf("FAILED CASES: %d\n", len(failedCases))
+}
+
+func TestCORSCredentialsWithAnyOrigin(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected CORS to panic when credentials are allowed for any origin")
+		}
+	}()
+	CORS(CORSOptions{AllowCredentials: true})
+}
+
+func TestDefaultHeaderOptIn(t *testing.T) {
+	g := NewServer()
+
+	_ = g.Get("/default-header/off", func(ctx *Ctx) error { return nil })
+	_ = g.Get("/default-header/on", func(ctx *Ctx) error { return nil })
+	g.Use("/default-header/on", DefaultHeader)
+
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/default-header/off", nil))
+	for _, h := range []string{"Access-Control-Allow-Origin", "Connection", "Keep-Alive"} {
+		if rec.Header().Get(h) != "" {
+			t.Errorf("expected no %s header by default, got %q", h, rec.Header().Get(h))
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/default-header/on", nil))
+	if rec.Header().Get("Access-Control-Allow-Origin") != "*" || rec.Header().Get("Keep-Alive") != "timeout=5, max=997" {
+		t.Errorf("expected DefaultHeader to set the default headers, got %v", rec.Header())
+	}
+}
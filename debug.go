@@ -0,0 +1,375 @@
+package goster
+
+import (
+	"bytes"
+	"expvar"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"reflect"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// WrapHTTP turns a http.Handler into a RequestHandler, so that handlers written for net/http can be used as routes:
+//
+//	g.Get("/debug/vars", goster.WrapHTTP(expvar.Handler()))
+func WrapHTTP(h http.Handler) RequestHandler {
+	return func(ctx *Ctx) error {
+		h.ServeHTTP(&ctx.Response, ctx.Request)
+		return nil
+	}
+}
+
+// RouteInfo describes a registered route, see Goster.RouteTable.
+type RouteInfo struct {
+	Method     string   `json:"method"`
+	Pattern    string   `json:"pattern"`
+	Type       string   `json:"type"`
+	Middleware []string `json:"middleware"` // Middleware are the names of the functions that run before the handler, global ones first
+	Handler    string   `json:"handler"`    // Handler is the name of the function handling the route
+}
+
+// RouteTable returns the registered routes sorted by pattern and method, along with the middleware that runs for them.
+func (g *Goster) RouteTable() []RouteInfo {
+	table := []RouteInfo{}
+	for method, routes := range g.Routes {
+		for pattern, route := range routes {
+			info := RouteInfo{Method: method, Pattern: pattern, Type: route.Type, Middleware: []string{}, Handler: funcName(route.Handler)}
+			for _, m := range g.Middleware["*"] {
+				info.Middleware = append(info.Middleware, funcName(m))
+			}
+			for _, m := range g.Middleware[pattern] {
+				info.Middleware = append(info.Middleware, funcName(m))
+			}
+			table = append(table, info)
+		}
+	}
+
+	sort.Slice(table, func(i, j int) bool {
+		if table[i].Pattern != table[j].Pattern {
+			return table[i].Pattern < table[j].Pattern
+		}
+		return table[i].Method < table[j].Method
+	})
+	return table
+}
+
+// funcName returns the name of the function fn, like "main.listUsers" or "github.com/dpouris/goster.AccessLog.func1" for closures
+func funcName(fn any) string {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return "<nil>"
+	}
+	if f := runtime.FuncForPC(v.Pointer()); f != nil {
+		return f.Name()
+	}
+	return "<unknown>"
+}
+
+// debugIndex is the page listing the debug endpoints
+var debugIndex = template.Must(template.New("debug").Parse(`<!doctype html>
+<html>
+<head><meta charset="utf-8"><title>goster debug</title></head>
+<body>
+<h1>goster debug</h1>
+<ul>
+<li><a href="{{.}}/pprof/">pprof</a>: CPU, heap, goroutine and other profiles</li>
+<li><a href="{{.}}/vars">vars</a>: expvar variables (memstats, cmdline and your own)</li>
+<li><a href="{{.}}/routes">routes</a>: registered routes with their middleware and handlers</li>
+<li><a href="{{.}}/config">config</a>: current configuration</li>
+<li><a href="{{.}}/requests">requests</a>: recently handled requests</li>
+</ul>
+</body>
+</html>
+`))
+
+// MountDebug registers routes under prefix that help diagnose a running server, guarded by middleware (like authentication):
+//
+//	prefix/             a page linking to the others
+//	prefix/pprof/       the profiles of runtime/pprof, e.g. prefix/pprof/heap or prefix/pprof/profile?seconds=10 for the CPU
+//	prefix/vars         the variables published with expvar, as JSON
+//	prefix/routes       the route table, see RouteTable (as JSON with "?format=json")
+//	prefix/config       the configuration of the server, as JSON
+//	prefix/requests     the most recent requests from the log buffer, as JSON ("?limit=n" to change the default of 100)
+//
+// These endpoints expose sensitive information and profiling has a cost, so never mount them without middleware
+// that keeps strangers out:
+//
+//	g.MountDebug("/debug", requireAdmin)
+//
+// The profiles are served without importing net/http/pprof, which would register its handlers on http.DefaultServeMux
+// of every program using goster. expvar is imported, so it registers /debug/vars there, as it does in any program that uses it.
+func (g *Goster) MountDebug(prefix string, middleware ...RequestHandler) error {
+	cleanPath(&prefix)
+
+	routes := []struct {
+		method  string
+		path    string
+		handler RequestHandler
+	}{
+		{http.MethodGet, prefix, func(ctx *Ctx) error {
+			ctx.Response.Header().Set("Content-Type", "text/html; charset=utf-8")
+			return debugIndex.Execute(&ctx.Response, prefix)
+		}},
+		{http.MethodGet, path.Join(prefix, "pprof", "*profile"), debugPprof(prefix)},
+		{http.MethodPost, path.Join(prefix, "pprof", "*profile"), debugPprof(prefix)},
+		{http.MethodGet, path.Join(prefix, "vars"), WrapHTTP(expvar.Handler())},
+		{http.MethodGet, path.Join(prefix, "routes"), g.debugRoutes},
+		{http.MethodGet, path.Join(prefix, "config"), g.debugConfig},
+		{http.MethodGet, path.Join(prefix, "requests"), g.debugRequests},
+	}
+
+	for _, r := range routes {
+		if err := g.Routes.New(r.method, r.path, r.handler); err != nil {
+			return err
+		}
+		if len(middleware) > 0 {
+			g.Use(r.path, middleware...)
+		}
+	}
+	return nil
+}
+
+// pprofIndex is the page listing the profiles under prefix/pprof/
+var pprofIndex = template.Must(template.New("pprof").Parse(`<!doctype html>
+<html>
+<head><meta charset="utf-8"><title>goster debug/pprof</title></head>
+<body>
+<h1>debug/pprof</h1>
+<table>
+<tr><th>Count</th><th>Profile</th></tr>
+{{- range .}}
+<tr><td>{{.Count}}</td><td><a href="{{.Name}}?debug=1">{{.Name}}</a></td></tr>
+{{- end}}
+<tr><td></td><td><a href="cmdline">cmdline</a></td></tr>
+<tr><td></td><td><a href="profile">profile</a> (CPU, 30 seconds by default)</td></tr>
+<tr><td></td><td><a href="trace?seconds=5">trace</a></td></tr>
+</table>
+</body>
+</html>
+`))
+
+// debugPprof serves the profiles of runtime/pprof under prefix/pprof/, following the paths and parameters of net/http/pprof
+// so that `go tool pprof` can fetch them
+func debugPprof(prefix string) RequestHandler {
+	return func(ctx *Ctx) error {
+		profile, _ := ctx.Path.Get("profile")
+		switch profile {
+		case "":
+			// the index links to the profiles relatively, so it has to be served under a trailing slash
+			if !strings.HasSuffix(ctx.Request.URL.Path, "/") {
+				http.Redirect(&ctx.Response, ctx.Request, path.Join(prefix, "pprof")+"/", http.StatusMovedPermanently)
+				return nil
+			}
+
+			profiles := pprof.Profiles()
+			sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name() < profiles[j].Name() })
+			ctx.Response.Header().Set("Content-Type", "text/html; charset=utf-8")
+			return pprofIndex.Execute(&ctx.Response, profiles)
+		case "cmdline":
+			ctx.Response.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			_, err := io.WriteString(&ctx.Response, strings.Join(os.Args, "\x00"))
+			return err
+		case "profile":
+			return debugCPUProfile(ctx)
+		case "symbol":
+			return debugSymbol(ctx)
+		case "trace":
+			return debugTrace(ctx)
+		default:
+			return debugProfile(ctx, profile)
+		}
+	}
+}
+
+// debugSeconds returns the duration of the "seconds" query parameter, or fallback if there is none
+func debugSeconds(ctx *Ctx, fallback int) (time.Duration, error) {
+	seconds := fallback
+	if s, _ := ctx.Query.Get("seconds"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return 0, NewProblem(http.StatusBadRequest, fmt.Sprintf("invalid seconds `%s`", s))
+		}
+		seconds = n
+	}
+
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// debugWait waits for d to pass, or until the client goes away
+func debugWait(ctx *Ctx, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-ctx.Context().Done():
+	}
+}
+
+// debugProfile sends the profile `name`, in the text format if the "debug" query parameter is set
+func debugProfile(ctx *Ctx, name string) error {
+	p := pprof.Lookup(name)
+	if p == nil {
+		return NewProblem(http.StatusNotFound, "Unknown profile")
+	}
+
+	debugParam, _ := ctx.Query.Get("debug")
+	debug, _ := strconv.Atoi(debugParam)
+	if gc, _ := ctx.Query.Get("gc"); gc != "" && gc != "0" && name == "heap" {
+		runtime.GC()
+	}
+
+	var buf bytes.Buffer
+	if err := p.WriteTo(&buf, debug); err != nil {
+		return err
+	}
+
+	if debug != 0 {
+		ctx.Response.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	} else {
+		ctx.Response.Header().Set("Content-Type", "application/octet-stream")
+		ctx.Response.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+	}
+	_, err := ctx.Response.Write(buf.Bytes())
+	return err
+}
+
+// debugCPUProfile profiles the CPU for the duration of the "seconds" query parameter (30 seconds by default) and sends the profile
+func debugCPUProfile(ctx *Ctx) error {
+	d, err := debugSeconds(ctx, 30)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := pprof.StartCPUProfile(&buf); err != nil {
+		return NewProblem(http.StatusInternalServerError, "could not enable CPU profiling: "+err.Error())
+	}
+	debugWait(ctx, d)
+	pprof.StopCPUProfile()
+
+	ctx.Response.Header().Set("Content-Type", "application/octet-stream")
+	ctx.Response.Header().Set("Content-Disposition", `attachment; filename="profile"`)
+	_, err = ctx.Response.Write(buf.Bytes())
+	return err
+}
+
+// debugTrace traces the execution of the program for the duration of the "seconds" query parameter (1 second by default)
+func debugTrace(ctx *Ctx) error {
+	d, err := debugSeconds(ctx, 1)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := trace.Start(&buf); err != nil {
+		return NewProblem(http.StatusInternalServerError, "could not enable tracing: "+err.Error())
+	}
+	debugWait(ctx, d)
+	trace.Stop()
+
+	ctx.Response.Header().Set("Content-Type", "application/octet-stream")
+	ctx.Response.Header().Set("Content-Disposition", `attachment; filename="trace"`)
+	_, err = ctx.Response.Write(buf.Bytes())
+	return err
+}
+
+// debugSymbol looks up the names of the functions at the program counters of the request, separated by '+' and given
+// in the query or, for POST requests, the body
+func debugSymbol(ctx *Ctx) error {
+	input := ctx.Request.URL.RawQuery
+	if ctx.Request.Method == http.MethodPost {
+		body, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			return err
+		}
+		input = string(body)
+	}
+
+	var buf bytes.Buffer
+	// pprof only checks whether the symbol endpoint exists, the count isn't used
+	buf.WriteString("num_symbols: 1\n")
+	for _, word := range strings.Split(input, "+") {
+		pc, err := strconv.ParseUint(word, 0, 64)
+		if err != nil {
+			continue
+		}
+		if f := runtime.FuncForPC(uintptr(pc)); f != nil {
+			fmt.Fprintf(&buf, "%#x %s\n", pc, f.Name())
+		}
+	}
+
+	ctx.Response.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, err := ctx.Response.Write(buf.Bytes())
+	return err
+}
+
+func (g *Goster) debugRoutes(ctx *Ctx) error {
+	table := g.RouteTable()
+	if format, _ := ctx.Query.Get("format"); format == "json" {
+		return ctx.JSON(table)
+	}
+
+	ctx.Response.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w := tabwriter.NewWriter(&ctx.Response, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "METHOD\tPATTERN\tTYPE\tMIDDLEWARE\tHANDLER")
+	for _, r := range table {
+		middleware := strings.Join(r.Middleware, " -> ")
+		if middleware == "" {
+			middleware = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Method, r.Pattern, r.Type, middleware, r.Handler)
+	}
+	return w.Flush()
+}
+
+func (g *Goster) debugConfig(ctx *Ctx) error {
+	logLevel := ""
+	if g.LogLevel != nil {
+		logLevel = g.LogLevel.Level().String()
+	}
+
+	return ctx.JSON(map[string]any{
+		"go_version":    runtime.Version(),
+		"goroutines":    runtime.NumGoroutine(),
+		"log_level":     logLevel,
+		"routes":        len(g.RouteTable()),
+		"metrics":       g.metrics != nil,
+		"config":        engine.Config,
+		"error_handler": funcName(g.errorHandler()),
+	})
+}
+
+func (g *Goster) debugRequests(ctx *Ctx) error {
+	limit := 100
+	if l, _ := ctx.Query.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			return NewProblem(http.StatusBadRequest, fmt.Sprintf("invalid limit `%s`", l))
+		}
+		limit = n
+	}
+
+	requests := []LogEntry{}
+	for _, e := range g.RecentLogs(LogFilter{}) {
+		if e.Message == "request" {
+			requests = append(requests, e)
+		}
+	}
+	if len(requests) > limit {
+		requests = requests[len(requests)-limit:]
+	}
+
+	return ctx.JSON(requests)
+}
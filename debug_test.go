@@ -0,0 +1,134 @@
+package goster
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type DebugCase struct {
+	name     string
+	url      string
+	auth     bool
+	status   int
+	expected string // expected is a string the body has to contain
+}
+
+func debugTestHandler(ctx *Ctx) error {
+	ctx.Text("user")
+	return nil
+}
+
+func requireDebugToken(ctx *Ctx) error {
+	if ctx.Request.Header.Get("Authorization") != "Bearer debug" {
+		return NewProblem(http.StatusUnauthorized, "")
+	}
+	return nil
+}
+
+func TestMountDebug(t *testing.T) {
+	g := NewServer()
+	if expvar.Get("goster_debug_test") == nil {
+		expvar.NewInt("goster_debug_test").Set(42)
+	}
+
+	if err := g.MountDebug("/debug-test/debug", requireDebugToken); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.MountDebug("/debug-test/debug"); err == nil {
+		t.Error("expected mounting twice under the same prefix to fail")
+	}
+	_ = g.Get("/debug-test/users/:id", debugTestHandler)
+	g.Use("/debug-test/users/:id", requireDebugToken)
+
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug-test/users/1", nil))
+
+	testCases := []DebugCase{
+		{"Unauthorized", "/debug-test/debug/routes", false, http.StatusUnauthorized, ""},
+		{"Unauthorized pprof", "/debug-test/debug/pprof/heap", false, http.StatusUnauthorized, ""},
+		{"Index", "/debug-test/debug", true, http.StatusOK, `href="/debug-test/debug/pprof/"`},
+		{"Pprof redirect", "/debug-test/debug/pprof", true, http.StatusMovedPermanently, ""},
+		{"Pprof index", "/debug-test/debug/pprof/", true, http.StatusOK, "goroutine"},
+		{"Pprof profile", "/debug-test/debug/pprof/goroutine?debug=1", true, http.StatusOK, "goroutine profile:"},
+		{"Pprof heap profile", "/debug-test/debug/pprof/heap?debug=1&gc=1", true, http.StatusOK, "heap profile:"},
+		{"Pprof cmdline", "/debug-test/debug/pprof/cmdline", true, http.StatusOK, ""},
+		{"Pprof symbol", "/debug-test/debug/pprof/symbol", true, http.StatusOK, "num_symbols: 1"},
+		{"Pprof invalid seconds", "/debug-test/debug/pprof/profile?seconds=x", true, http.StatusBadRequest, "invalid seconds"},
+		{"Pprof unknown profile", "/debug-test/debug/pprof/nothing", true, http.StatusNotFound, "Unknown profile"},
+		{"Expvar", "/debug-test/debug/vars", true, http.StatusOK, `"memstats"`},
+		{"Published expvar", "/debug-test/debug/vars", true, http.StatusOK, `"goster_debug_test": 42`},
+		{"Route table", "/debug-test/debug/routes", true, http.StatusOK, "goster.debugTestHandler"},
+		{"Route middleware", "/debug-test/debug/routes", true, http.StatusOK, "goster.requireDebugToken"},
+		{"Config", "/debug-test/debug/config", true, http.StatusOK, `"log_level"`},
+		{"Recent requests", "/debug-test/debug/requests", true, http.StatusOK, `"/debug-test/users/1"`},
+		{"Invalid limit", "/debug-test/debug/requests?limit=x", true, http.StatusBadRequest, ""},
+	}
+
+	failedCases := make(map[int]DebugCase, 0)
+	for i, c := range testCases {
+		req := httptest.NewRequest(http.MethodGet, c.url, nil)
+		if c.auth {
+			req.Header.Set("Authorization", "Bearer debug")
+		}
+		rec := httptest.NewRecorder()
+		g.ServeHTTP(rec, req)
+
+		if rec.Code != c.status || !strings.Contains(rec.Body.String(), c.expected) {
+			failedCases[i] = c
+			t.Errorf("FAILED [%d] - %s: expected %d containing %q, got %d: %s", i, c.name, c.status, c.expected, rec.Code, rec.Body.String())
+		} else {
+			t.Logf("PASSED [%d] - %s\n", i, c.name)
+		}
+	}
+
+	t.Logf("TOTAL CASES: %d\n", len(testCases))
+	t.Logf("FAILED CASES: %d\n", len(failedCases))
+}
+
+// TestMountDebugDefaultServeMux verifies that the profiles aren't registered on http.DefaultServeMux, like importing
+// net/http/pprof would do
+func TestMountDebugDefaultServeMux(t *testing.T) {
+	if _, pattern := http.DefaultServeMux.Handler(httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil)); pattern != "" {
+		t.Errorf("expected nothing to be registered for /debug/pprof/ on http.DefaultServeMux, got %q", pattern)
+	}
+}
+
+func TestRouteTable(t *testing.T) {
+	g := NewServer()
+
+	_ = g.Get("/route-table/items/:id", debugTestHandler)
+	_ = g.Delete("/route-table/items/:id", debugTestHandler)
+	g.Use("/route-table/items/:id", requireDebugToken)
+
+	rec := httptest.NewRecorder()
+	_ = g.Get("/route-table/json", WrapHTTP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(g.RouteTable())
+	})))
+	g.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/route-table/json", nil))
+
+	table := []RouteInfo{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &table); err != nil {
+		t.Fatalf("WrapHTTP didn't write the route table: %v", err)
+	}
+
+	found := []RouteInfo{}
+	for _, r := range table {
+		if r.Pattern == "/route-table/items/:id" {
+			found = append(found, r)
+		}
+	}
+
+	if len(found) != 2 || found[0].Method != http.MethodDelete || found[1].Method != http.MethodGet {
+		t.Fatalf("expected the DELETE and GET routes sorted by method, got %+v", found)
+	}
+	for _, r := range found {
+		if r.Type != "dynamic" || !strings.HasSuffix(r.Handler, "goster.debugTestHandler") ||
+			len(r.Middleware) == 0 || !strings.HasSuffix(r.Middleware[len(r.Middleware)-1], "goster.requireDebugToken") {
+			t.Errorf("unexpected route info %+v", r)
+		}
+	}
+}
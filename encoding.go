@@ -0,0 +1,302 @@
+package goster
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrNotAcceptable is returned by Ctx.Negotiate when none of the registered encoders produces a media type the client accepts.
+var ErrNotAcceptable = errors.New("goster: no acceptable representation for the response")
+
+// Encoder encodes values into a specific media type so that they can be sent to the client.
+type Encoder interface {
+	// MediaType returns the media type the encoder produces (e.g. "application/json").
+	MediaType() string
+	// Encode writes the encoded representation of v to w.
+	Encode(w io.Writer, v any) error
+}
+
+// encoderFunc is an Encoder made of a media type and a function.
+type encoderFunc struct {
+	mediaType string
+	encode    func(w io.Writer, v any) error
+}
+
+func (e encoderFunc) MediaType() string               { return e.mediaType }
+func (e encoderFunc) Encode(w io.Writer, v any) error { return e.encode(w, v) }
+
+// NewEncoder creates an Encoder for mediaType that uses encode to encode values. It's the easiest way
+// to plug in formats like MessagePack:
+//
+//	g.RegisterEncoder(goster.NewEncoder("application/msgpack", func(w io.Writer, v any) error {
+//		return msgpack.NewEncoder(w).Encode(v)
+//	}))
+func NewEncoder(mediaType string, encode func(w io.Writer, v any) error) Encoder {
+	return encoderFunc{mediaType: mediaType, encode: encode}
+}
+
+// JSONEncoder encodes values as JSON using encoding/json. Values of type []byte and json.RawMessage are written as they are.
+type JSONEncoder struct{}
+
+func (JSONEncoder) MediaType() string { return "application/json" }
+
+func (JSONEncoder) Encode(w io.Writer, v any) (err error) {
+	switch b := v.(type) {
+	case []byte:
+		_, err = w.Write(b)
+		return
+	case json.RawMessage:
+		_, err = w.Write(b)
+		return
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+
+	_, err = w.Write(b)
+	return
+}
+
+// XMLEncoder encodes values as XML using encoding/xml.
+type XMLEncoder struct{}
+
+func (XMLEncoder) MediaType() string { return "application/xml" }
+
+func (XMLEncoder) Encode(w io.Writer, v any) (err error) {
+	_, err = io.WriteString(w, xml.Header)
+	if err != nil {
+		return
+	}
+
+	return xml.NewEncoder(w).Encode(v)
+}
+
+// TextEncoder encodes values as plain text. Strings and []byte are written as they are, anything else is formatted with fmt.
+type TextEncoder struct{}
+
+func (TextEncoder) MediaType() string { return "text/plain" }
+
+func (TextEncoder) Encode(w io.Writer, v any) (err error) {
+	switch t := v.(type) {
+	case string:
+		_, err = io.WriteString(w, t)
+	case []byte:
+		_, err = w.Write(t)
+	default:
+		_, err = fmt.Fprint(w, v)
+	}
+
+	return
+}
+
+// CSVEncoder encodes values as CSV using encoding/csv. It supports [][]string, [][]any (each cell formatted with fmt)
+// and []string (a single record).
+type CSVEncoder struct{}
+
+func (CSVEncoder) MediaType() string { return "text/csv" }
+
+func (CSVEncoder) Encode(w io.Writer, v any) error {
+	cw := csv.NewWriter(w)
+
+	switch records := v.(type) {
+	case [][]string:
+		return cw.WriteAll(records)
+	case []string:
+		return cw.WriteAll([][]string{records})
+	case [][]any:
+		for _, record := range records {
+			row := make([]string, len(record))
+			for i, cell := range record {
+				row[i] = fmt.Sprint(cell)
+			}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+
+	return fmt.Errorf("goster: cannot encode %T as csv", v)
+}
+
+// defaultEncoders returns the encoders every server starts with, in order of preference.
+func defaultEncoders() []Encoder {
+	return []Encoder{JSONEncoder{}, XMLEncoder{}, TextEncoder{}, CSVEncoder{}}
+}
+
+// RegisterEncoder adds encoders to the ones Ctx.Negotiate picks from. An encoder replaces a registered one
+// with the same media type, otherwise it's added last, after the encoders registered before it.
+func (g *Goster) RegisterEncoder(encoders ...Encoder) {
+	for _, e := range encoders {
+		replaced := false
+		for i := range g.Encoders {
+			if strings.EqualFold(g.Encoders[i].MediaType(), e.MediaType()) {
+				g.Encoders[i] = e
+				replaced = true
+				break
+			}
+		}
+
+		if !replaced {
+			g.Encoders = append(g.Encoders, e)
+		}
+	}
+}
+
+// Negotiate sends data to the client encoded with the registered encoder that best matches the Accept header of the request,
+// taking the q-values into account. If the request has no Accept header, the first registered encoder (JSON by default) is used.
+//
+// If none of the encoders produces a media type the client accepts, nothing is sent and ErrNotAcceptable is returned.
+// Returned from a handler, the error handler of the server responds with `http.StatusNotAcceptable`.
+func (c *Ctx) Negotiate(data any) (err error) {
+	c.Response.Header().Add("Vary", "Accept")
+
+	enc, ok := negotiateEncoder(c.Request.Header.Get("Accept"), c.encoders())
+	if !ok {
+		return ErrNotAcceptable
+	}
+
+	return c.encode(enc, enc.MediaType(), 0, data)
+}
+
+// encoders returns the encoders registered on the server handling the request.
+func (c *Ctx) encoders() []Encoder {
+	if c.g == nil || len(c.g.Encoders) == 0 {
+		return defaultEncoders()
+	}
+
+	return c.g.Encoders
+}
+
+// encode encodes data with enc and sends it with the given content type and status code (unless it's 0).
+// The data is encoded in a buffer first, so that nothing is sent to the client if encoding fails.
+func (c *Ctx) encode(enc Encoder, contentType string, status int, data any) (err error) {
+	var buf bytes.Buffer
+	err = enc.Encode(&buf, data)
+	if err != nil {
+		return
+	}
+
+	if strings.HasPrefix(contentType, "text/") && !strings.Contains(contentType, "charset") {
+		contentType += "; charset=utf-8"
+	}
+	c.Response.Header().Set("Content-Type", contentType)
+	if status != 0 {
+		c.Response.WriteHeader(status)
+	}
+	_, err = c.Response.Write(buf.Bytes())
+	return
+}
+
+// acceptRange is a single media range of an Accept header along with its quality
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+// parseAccept parses the Accept header h into its media ranges.
+func parseAccept(h string) []acceptRange {
+	ranges := []acceptRange{}
+	for _, part := range strings.Split(h, ",") {
+		mediaType, params, _ := strings.Cut(part, ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+		if mediaType == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, _ := strings.Cut(param, "=")
+			if strings.TrimSpace(key) != "q" {
+				continue
+			}
+			if v, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && v >= 0 && v <= 1 {
+				q = v
+			}
+		}
+
+		ranges = append(ranges, acceptRange{mediaType: mediaType, q: q})
+	}
+
+	return ranges
+}
+
+// acceptQuality returns the quality the media ranges give to mediaType. The most specific matching range wins,
+// so "text/html" takes precedence over "text/*" which takes precedence over "*/*".
+func acceptQuality(ranges []acceptRange, mediaType string) (q float64) {
+	mediaType = strings.ToLower(mediaType)
+	mainType, _, _ := strings.Cut(mediaType, "/")
+
+	specificity := -1
+	for _, r := range ranges {
+		s := -1
+		switch {
+		case r.mediaType == mediaType:
+			s = 2
+		case r.mediaType == mainType+"/*":
+			s = 1
+		case r.mediaType == "*/*":
+			s = 0
+		}
+
+		if s > specificity {
+			specificity = s
+			q = r.q
+		}
+	}
+
+	return
+}
+
+// acceptEncodingQuality returns the quality the Accept-Encoding header h gives to the content coding `coding`.
+// An explicit entry for the coding takes precedence over "*".
+func acceptEncodingQuality(h string, coding string) (q float64) {
+	specificity := -1
+	for _, r := range parseAccept(h) {
+		s := -1
+		switch r.mediaType {
+		case coding:
+			s = 1
+		case "*":
+			s = 0
+		}
+
+		if s > specificity {
+			specificity = s
+			q = r.q
+		}
+	}
+
+	return
+}
+
+// negotiateEncoder picks the encoder whose media type the Accept header accept prefers. Encoders earlier in the list win ties.
+func negotiateEncoder(accept string, encoders []Encoder) (enc Encoder, ok bool) {
+	if len(encoders) == 0 {
+		return
+	}
+
+	if strings.TrimSpace(accept) == "" {
+		return encoders[0], true
+	}
+
+	ranges := parseAccept(accept)
+	best := 0.0
+	for _, e := range encoders {
+		if q := acceptQuality(ranges, e.MediaType()); q > best {
+			enc, best, ok = e, q, true
+		}
+	}
+
+	return
+}
@@ -0,0 +1,91 @@
+package goster
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type NegotiateCase struct {
+	name                string
+	accept              string
+	expectedStatus      int
+	expectedContentType string
+}
+
+func TestNegotiate(t *testing.T) {
+	g := NewServer()
+	_ = g.Get("/negotiate", func(ctx *Ctx) error {
+		return ctx.Negotiate([][]string{{"name", "age"}, {"dimitris", "24"}})
+	})
+
+	testCases := []NegotiateCase{
+		{"No Accept header", "", http.StatusOK, "application/json"},
+		{"Exact match", "text/csv", http.StatusOK, "text/csv; charset=utf-8"},
+		{"Q-values", "application/json;q=0.5, text/csv;q=0.9", http.StatusOK, "text/csv; charset=utf-8"},
+		{"Specific range wins over wildcard", "text/*;q=0.8, text/csv;q=0.1, */*;q=0.2", http.StatusOK, "text/plain; charset=utf-8"},
+		{"Wildcard", "*/*", http.StatusOK, "application/json"},
+		{"Excluded media type", "application/json;q=0, */*;q=0.1", http.StatusOK, "application/xml"},
+		{"Not acceptable", "image/png", http.StatusNotAcceptable, "application/problem+json"},
+	}
+
+	failedCases := make(map[int]NegotiateCase, 0)
+	for i, c := range testCases {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/negotiate", nil)
+		if c.accept != "" {
+			req.Header.Set("Accept", c.accept)
+		}
+		g.ServeHTTP(rec, req)
+
+		if rec.Code != c.expectedStatus || rec.Header().Get("Content-Type") != c.expectedContentType {
+			failedCases[i] = c
+			t.Errorf("FAILED [%d] - %s: got %d %q", i, c.name, rec.Code, rec.Header().Get("Content-Type"))
+		} else {
+			t.Logf("PASSED [%d] - %s\n", i, c.name)
+		}
+	}
+
+	t.Logf("TOTAL CASES: %d\n", len(testCases))
+	t.Logf("FAILED CASES: %d\n", len(failedCases))
+}
+
+func TestNegotiateNotAcceptable(t *testing.T) {
+	g := NewServer()
+	_ = g.Get("/negotiate-not-acceptable", func(ctx *Ctx) error {
+		err := ctx.Negotiate("data")
+		if !errors.Is(err, ErrNotAcceptable) {
+			t.Errorf("expected ErrNotAcceptable, got %v", err)
+		}
+		if ctx.Response.Committed() {
+			t.Error("expected Negotiate to leave the response to the error handler")
+		}
+		return err
+	})
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/negotiate-not-acceptable", nil)
+	req.Header.Set("Accept", "image/png")
+	g.ServeHTTP(rec, req)
+
+	var problem map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil || rec.Code != http.StatusNotAcceptable || problem["status"] != float64(http.StatusNotAcceptable) {
+		t.Errorf("expected a single 406 problem, got %d %q (%v)", rec.Code, rec.Body.String(), err)
+	}
+}
+
+func TestJSONBytes(t *testing.T) {
+	rec := httptest.NewRecorder()
+	ctx := Ctx{Request: httptest.NewRequest(http.MethodGet, "/", nil), Response: newResponse(rec)}
+
+	payload := []byte("{\"data\":\"a\x00b\"}")
+	if err := ctx.JSON(payload); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec.Body.String() != string(payload) {
+		t.Errorf("expected body %q, got %q", payload, rec.Body.String())
+	}
+}
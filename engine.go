@@ -2,8 +2,9 @@ package goster
 
 import (
 	"fmt"
+	"html/template"
 	"io/fs"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"slices"
@@ -11,16 +12,23 @@ import (
 )
 
 type Engine struct {
-	Goster  *Goster
-	startUp sync.Once
-	Config  *Config
+	Goster        *Goster
+	startUp       sync.Once
+	Config        *Config
+	templates     templateCache
+	templateFuncs template.FuncMap // templateFuncs are the functions registered with Goster.TemplateFuncs
+	templateFS    fs.FS            // templateFS is the file system templates are read from, nil if they're read from disk
+	staticMounts  []*staticHandler // staticMounts are the static mounts with fingerprinting enabled, looked up by Goster.Asset
 }
 
 type Config struct {
-	BaseTemplateDir string
-	StaticDir       string
-	TemplatePaths   map[string]string
-	StaticFilePaths map[string]string
+	BaseTemplateDir    string
+	StaticDir          string
+	TemplatePaths      map[string]string
+	DevMode            bool        // DevMode makes the engine pick up changes to the template directory without a restart
+	SharedTemplateDirs []string    // SharedTemplateDirs are the directories (relative to BaseTemplateDir) of the templates every template can use, like layouts and partials
+	StaticPrefix       string      // StaticPrefix is the URL path Goster.Asset and the `asset` template helper resolve relative paths against, the prefix of the first static mount unless set
+	StaticCacheRules   []CacheRule // StaticCacheRules set the Cache-Control header of static files, see Goster.CacheControl
 }
 
 var engine = Engine{}
@@ -28,14 +36,16 @@ var engine = Engine{}
 // init will run only once and set all the necessary fields for our one and only Goster instance
 func (e *Engine) init() *Goster {
 	initial := func() {
-		logger := log.New(os.Stdout, "[SERVER] - ", log.LstdFlags)
+		logLevel := new(slog.LevelVar)
+		logs := NewLogBuffer(DefaultLogBufferSize)
+		logger := slog.New(logs.Handler(NewLogger(os.Stdout, LogFormatText, logLevel).Handler()))
 		methods := make(map[string]map[string]Route)
 		methods["GET"] = make(map[string]Route)
 		methods["POST"] = make(map[string]Route)
 		methods["PUT"] = make(map[string]Route)
 		methods["PATCH"] = make(map[string]Route)
 		methods["DELETE"] = make(map[string]Route)
-		e.Goster = &Goster{Routes: methods, Middleware: make(map[string][]RequestHandler), Logger: logger}
+		e.Goster = &Goster{Routes: methods, Middleware: make(map[string][]RequestHandler), Logger: logger, LogLevel: logLevel, Logs: logs, Encoders: defaultEncoders(), ErrorHandler: DefaultErrorHandler, health: &Health{}}
 	}
 
 	// should set up config in here
@@ -46,118 +56,127 @@ func (e *Engine) init() *Goster {
 	return e.Goster
 }
 
-// Set the default config settings for the engine
+// logger returns the logger of the server, or the default logger of slog if the server hasn't been created yet
+func (e *Engine) logger() *slog.Logger {
+	if e.Goster == nil || e.Goster.Logger == nil {
+		return slog.Default()
+	}
+
+	return e.Goster.Logger
+}
+
+// Set the default config settings for the engine. The template source is reset along with it.
 func (e *Engine) DefaultConfig() {
+	e.templateFS = nil
 	e.Config = &Config{
-		StaticDir:       "",
-		BaseTemplateDir: "",
-		TemplatePaths:   make(map[string]string, 0),
-		StaticFilePaths: make(map[string]string, 0),
+		StaticDir:          "",
+		BaseTemplateDir:    "",
+		TemplatePaths:      make(map[string]string, 0),
+		SharedTemplateDirs: []string{"layouts", "partials"},
 	}
 }
 
-// Sets the template directory to `d` relative to the path of the executable.
+// SetTemplateDir makes the engine read templates from the directory at path, replacing any previous template source.
+// Relative paths are resolved against the working directory first and then against the directory of the executable.
+// If the directory doesn't exist an error is returned.
 func (e *Engine) SetTemplateDir(path string) (err error) {
 	templateDir, err := resolveAppPath(path)
 	if err != nil {
 		return
 	}
 
-	// if the given directory doesn't exist, create it and report it
-	if exists := pathExists(templateDir); !exists {
-		err = os.Mkdir(templateDir, 0o711) // rwx--x--x (o+rwx) (g+x) (u+x)
+	e.templates.closeWatcher()
+	templatesMap, err := walkTemplateDir(templateDir)
+	if err != nil {
+		err = fmt.Errorf("%s is not a valid template directory: %w", path, err)
+		return
+	}
 
-		if err != nil {
-			err = fmt.Errorf("template dir couldn't be created: %s", err)
-			return
-		}
+	e.templateFS = nil
+	e.Config.BaseTemplateDir = templateDir
+	return e.loadTemplates(templatesMap)
+}
 
-		fmt.Printf("[ENGINE INFO] - given template path `%s` doesn't exist\n", path)
-		fmt.Printf("[ENGINE INFO] - creating `%s`...\n", path)
+// SetTemplateFS makes the engine read templates from fsys, replacing any previous template source.
+// Templates read from an fs.FS aren't watched in dev mode.
+func (e *Engine) SetTemplateFS(fsys fs.FS) (err error) {
+	e.templates.closeWatcher()
+	templatesMap, err := walkTemplateFS(fsys)
+	if err != nil {
+		return
 	}
-	e.Config.BaseTemplateDir = templateDir
+
+	e.templateFS = fsys
+	e.Config.BaseTemplateDir = ""
+	return e.loadTemplates(templatesMap)
+}
+
+// loadTemplates records the templates of templatesMap as the templates of the engine and parses them.
+func (e *Engine) loadTemplates(templatesMap map[string]string) error {
+	e.templates.mu.Lock()
+	e.Config.TemplatePaths = make(map[string]string, len(templatesMap))
+	e.templates.mu.Unlock()
+
+	for templ := range templatesMap {
+		e.logger().Debug("recorded template", "template", templ, "path", templatesMap[templ])
+		e.Config.AddTemplatePath(templ, templatesMap[templ])
+	}
+
+	// parse all the templates once so that rendering them doesn't have to
+	return e.parseTemplates()
+}
+
+// walkTemplateDir walks templateDir and returns the path of every template in it, keyed by its path relative to templateDir.
+func walkTemplateDir(templateDir string) (map[string]string, error) {
+	templatesMap, err := walkTemplateFS(os.DirFS(templateDir))
+	for templ := range templatesMap {
+		templatesMap[templ] = filepath.Join(templateDir, filepath.FromSlash(templ))
+	}
+
+	return templatesMap, err
+}
+
+// walkTemplateFS walks fsys and returns the path of every template in it, keyed by the same path.
+func walkTemplateFS(fsys fs.FS) (map[string]string, error) {
 	templatesMap := make(map[string]string)
-	err = filepath.WalkDir(templateDir, func(path string, d fs.DirEntry, err error) error {
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
-			return fmt.Errorf("cannot walk %s dir", templateDir)
+			return fmt.Errorf("cannot walk template dir: %w", err)
 		}
 		if !d.IsDir() {
-			relativePath, err := filepath.Rel(templateDir, path)
-			if err != nil {
-				return err
-			}
 			templateExts := []string{".html", ".gohtml"}
 			fileExt := filepath.Ext(d.Name())
 			if slices.Contains(templateExts, fileExt) {
-				templatesMap[relativePath] = path
+				templatesMap[path] = path
 			}
 		}
 		return nil
 	})
 
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "%s is not a valid directory\n", path)
-		return
-	}
+	return templatesMap, err
+}
 
-	for templ := range templatesMap {
-		fmt.Printf("[ENGINE INFO] - Recorded template `%s` -> %s\n", templ, templatesMap[templ])
-		if !e.Config.AddTemplatePath(templ, templatesMap[templ]) {
-			return fmt.Errorf("template `%s` already exists in `%s`", templ, e.Config.BaseTemplateDir)
-		}
+// readTemplate reads the template file at path, either from the fs.FS of the engine or from disk.
+func (e *Engine) readTemplate(path string) ([]byte, error) {
+	if e.templateFS != nil {
+		return fs.ReadFile(e.templateFS, path)
 	}
 
-	return
+	return os.ReadFile(path)
 }
 
+// SetStaticDir makes the directory at path the static directory. Relative paths are resolved the same way as in SetTemplateDir.
+// The files aren't recorded, they're looked up when they're requested. If the directory doesn't exist an error is returned.
 func (e *Engine) SetStaticDir(path string) (err error) {
 	staticPath, err := resolveAppPath(path)
 	if err != nil {
 		return err
 	}
 
-	// if the given directory doesn't exist, create it and report it
-	if exists := pathExists(staticPath); !exists {
-		err = os.Mkdir(staticPath, 0o711) // rwx--x--x (o+rwx) (g+x) (u+x)
-
-		if err != nil {
-			err = fmt.Errorf("static dir couldn't be created: %s", err)
-			return
-		}
-
-		fmt.Printf("[ENGINE INFO] - given static path `%s` doesn't exist\n", path)
-		fmt.Printf("[ENGINE INFO] - creating static path `%s`...\n", path)
-	}
-
 	e.Config.StaticDir = staticPath
-	staticFileMap := make(map[string]string)
-	// walk the directory and register a GET route for each file found
-	err = filepath.WalkDir(staticPath, func(filePath string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// process only files (skip directories)
-		if !d.IsDir() {
-			// compute the route path relative to the static directory
-			relPath, _ := filepath.Rel(staticPath, filePath)
-			cleanPath(&relPath)
-
-			staticFileMap[relPath] = filePath
-		}
-
-		return nil
-	})
-
-	for relPath := range staticFileMap {
-		fmt.Printf("[ENGINE INFO] - Recorded static file `%s` -> %s\n", relPath, staticFileMap[relPath])
-		if !e.Config.AddStaticFilePath(relPath, staticFileMap[relPath]) {
-			return fmt.Errorf("static file `%s` already exists in `%s`", relPath, e.Config.StaticDir)
-		}
-	}
-
 	return
 }
+
 func (c *Config) AddTemplatePath(relPath string, fullPath string) (added bool) {
 	// check if path exists
 	_, exists := c.TemplatePaths[relPath]
@@ -172,18 +191,3 @@ func (c *Config) AddTemplatePath(relPath string, fullPath string) (added bool) {
 	added = false
 	return
 }
-
-func (c *Config) AddStaticFilePath(relPath string, fullPath string) (added bool) {
-	// check if path exists
-	_, exists := c.StaticFilePaths[relPath]
-
-	if !exists {
-		// add if it doesn't
-		c.StaticFilePaths[relPath] = fullPath
-		added = true
-		return
-	}
-
-	added = false
-	return
-}
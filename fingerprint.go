@@ -0,0 +1,236 @@
+package goster
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// immutableCacheControl is the Cache-Control header value of fingerprinted files. Their content never changes
+// since a change results in a new name, so they can be cached for as long as possible.
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+// fingerprintLength is the number of hex characters of the content hash in fingerprinted names
+const fingerprintLength = 8
+
+// fingerprintedFile is a static file that is served under a name containing the hash of its content too. The size and
+// modification time the file had when it was hashed tell whether it changed since.
+type fingerprintedFile struct {
+	name    string    // name is the actual name of the file
+	hashed  string    // hashed is the fingerprinted name of the file (e.g. "css/app.3f9a1c2b.css")
+	size    int64     // size is the size of the file when it was hashed
+	modTime time.Time // modTime is the modification time of the file when it was hashed
+}
+
+// fingerprintNames returns the names of the files of fsys that get fingerprinted. Precompressed siblings (like "app.js.br")
+// aren't fingerprinted on their own, they're served in place of the fingerprinted name of the file they belong to.
+func fingerprintNames(fsys fs.FS) (names []string, err error) {
+	err = fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		if !isPrecompressedSibling(fsys, name) {
+			names = append(names, name)
+		}
+		return nil
+	})
+
+	return
+}
+
+// fingerprint hashes the files of the file system so that they're served under their fingerprinted names too.
+func (sh *staticHandler) fingerprint() error {
+	names, err := fingerprintNames(sh.fsys)
+	if err != nil {
+		return err
+	}
+
+	sh.fingerprints = make(map[string]*fingerprintedFile, len(names))
+	sh.fingerprinted = make(map[string]*fingerprintedFile, len(names))
+	for _, name := range names {
+		info, err := fs.Stat(sh.fsys, name)
+		if err != nil {
+			return err
+		}
+		if _, err := sh.hash(name, info); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// hash hashes the file `name`, whose current info is info, and records its fingerprinted name. The previous
+// fingerprinted name of the file, if any, isn't served anymore.
+func (sh *staticHandler) hash(name string, info fs.FileInfo) (*fingerprintedFile, error) {
+	hash, err := hashFile(sh.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	file := &fingerprintedFile{name: name, hashed: fingerprintedName(name, hash), size: info.Size(), modTime: info.ModTime()}
+
+	sh.fingerprintsMu.Lock()
+	defer sh.fingerprintsMu.Unlock()
+
+	if previous, exists := sh.fingerprints[name]; exists {
+		delete(sh.fingerprinted, previous.hashed)
+	}
+	sh.fingerprints[name] = file
+	sh.fingerprinted[file.hashed] = file
+	return file, nil
+}
+
+// current returns the fingerprinted file `name` as it is now. A file that changed since it was hashed is hashed again,
+// so that its old fingerprinted name never serves the new content. If the file isn't fingerprinted or doesn't exist
+// anymore `ok` will be false
+func (sh *staticHandler) current(name string) (file *fingerprintedFile, ok bool) {
+	sh.fingerprintsMu.RLock()
+	file, ok = sh.fingerprints[name]
+	sh.fingerprintsMu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+
+	info, err := fs.Stat(sh.fsys, name)
+	if err != nil || info.IsDir() {
+		return nil, false
+	}
+	if info.Size() == file.size && info.ModTime().Equal(file.modTime) {
+		return file, true
+	}
+
+	file, err = sh.hash(name, info)
+	return file, err == nil
+}
+
+// original returns the actual name of the file with the fingerprinted name `hashed`. If the content of the file
+// doesn't match the hash anymore `ok` will be false
+func (sh *staticHandler) original(hashed string) (name string, ok bool) {
+	sh.fingerprintsMu.RLock()
+	file, ok := sh.fingerprinted[hashed]
+	sh.fingerprintsMu.RUnlock()
+	if !ok {
+		return "", false
+	}
+
+	file, ok = sh.current(file.name)
+	if !ok || file.hashed != hashed {
+		return "", false
+	}
+	return file.name, true
+}
+
+// manifest adds the URLs of the fingerprinted files of the handler to m, keyed by the URLs of the files under their plain names.
+func (sh *staticHandler) manifest(m map[string]string) {
+	sh.fingerprintsMu.RLock()
+	names := make([]string, 0, len(sh.fingerprints))
+	for name := range sh.fingerprints {
+		names = append(names, name)
+	}
+	sh.fingerprintsMu.RUnlock()
+
+	for _, name := range names {
+		if file, ok := sh.current(name); ok {
+			m[path.Join(sh.prefix, name)] = path.Join(sh.prefix, file.hashed)
+		}
+	}
+}
+
+// isPrecompressedSibling reports whether `name` is the precompressed version of another file of fsys
+func isPrecompressedSibling(fsys fs.FS, name string) bool {
+	for _, enc := range precompressedEncodings {
+		if original, found := strings.CutSuffix(name, enc.ext); found {
+			if _, err := fs.Stat(fsys, original); err == nil {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// hashFile returns the first characters of the hex encoded SHA-256 hash of the file `name` of fsys
+func hashFile(fsys fs.FS, name string) (string, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))[:fingerprintLength], nil
+}
+
+// fingerprintedName inserts hash before the extension of name, so that "css/app.css" becomes "css/app.<hash>.css"
+func fingerprintedName(name, hash string) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// Asset returns the URL of the static file p (e.g. "css/app.css"), relative to Config.StaticPrefix, which is the prefix
+// of the first static directory mounted with StaticDir or StaticFS unless it's set explicitly. If the static files are
+// fingerprinted (see StaticOptions.Fingerprint), the URL of the fingerprinted file is returned, like "/static/css/app.3f9a1c2b.css".
+// Files of other mounts are looked up by their full URL path, like "/vendor/css/app.css". Templates can use the `asset` helper instead.
+func (g *Goster) Asset(p string) string {
+	mounted := path.Join("/", engine.Config.StaticPrefix, p)
+	if u, exists := fingerprintedURL(mounted); exists {
+		return u
+	}
+	if u, exists := fingerprintedURL(path.Join("/", p)); exists {
+		return u
+	}
+
+	return mounted
+}
+
+// fingerprintedURL returns the fingerprinted URL of the static file served at the URL path u. If the file isn't
+// fingerprinted `exists` will be false
+func fingerprintedURL(u string) (fingerprinted string, exists bool) {
+	// like the router, pick the mount with the longest prefix
+	var mount *staticHandler
+	for _, sh := range engine.staticMounts {
+		if (sh.prefix == "/" || strings.HasPrefix(u, sh.prefix+"/")) && (mount == nil || len(sh.prefix) > len(mount.prefix)) {
+			mount = sh
+		}
+	}
+	if mount == nil {
+		return "", false
+	}
+
+	file, exists := mount.current(strings.TrimPrefix(strings.TrimPrefix(u, mount.prefix), "/"))
+	if !exists {
+		return "", false
+	}
+	return path.Join(mount.prefix, file.hashed), true
+}
+
+// WriteManifest writes the URLs of the fingerprinted static files to the file at p as JSON, keyed by the URL paths
+// of the files under their plain names:
+//
+//	{"/static/css/app.css": "/static/css/app.3f9a1c2b.css"}
+//
+// It lets tools outside of the server (e.g. a CDN upload step or a separate frontend build) find the fingerprinted files.
+func (g *Goster) WriteManifest(p string) error {
+	fingerprints := map[string]string{}
+	for _, sh := range engine.staticMounts {
+		sh.manifest(fingerprints)
+	}
+
+	manifest, err := json.MarshalIndent(fingerprints, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(p, append(manifest, '\n'), 0o644)
+}
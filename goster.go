@@ -1,22 +1,39 @@
 package goster
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"sync"
+	"time"
 )
 
 // Goster is the main structure of the package. It handles the addition of new routes and middleware, and manages logging.
 type Goster struct {
-	Routes     Routes                      // Routes is a map of HTTP methods to their respective route handlers.
-	Middleware map[string][]RequestHandler // Middleware is a map of routes to their respective middleware handlers.
-	Logger     *log.Logger                 // Logger is used for logging information and errors.
-	Logs       []string                    // Logs stores logs for future reference.
+	Routes       Routes                      // Routes is a map of HTTP methods to their respective route handlers.
+	Middleware   map[string][]RequestHandler // Middleware is a map of routes to their respective middleware handlers.
+	Logger       *slog.Logger                // Logger is used for logging information and errors.
+	LogLevel     *slog.LevelVar              // LogLevel is the minimum level of the records the default Logger writes. It has no effect on a replaced Logger.
+	Logs         *LogBuffer                  // Logs keeps the most recent log records of Logger, see RecentLogs
+	Encoders     []Encoder                   // Encoders are the encoders Ctx.Negotiate picks from, in order of preference.
+	ErrorHandler ErrorHandler                // ErrorHandler handles errors returned by middleware and handlers, as well as unmatched routes.
+	routeNames   map[string]string           // routeNames maps the names given with NameRoute to route paths.
+	metrics      *metrics                    // metrics records the requests once MountMetrics has been called.
+	health       *Health                     // health keeps the health checks, see Health.
+	serverMu     sync.Mutex                  // serverMu guards server and shutdownPending.
+	server       *http.Server                // server is the server started by Start or StartTLS while it runs, see Shutdown.
+
+	shutdownPending bool // shutdownPending is set when Shutdown is called while no server runs, so that the next one doesn't start
 }
 
 // Route represents an HTTP route with a type and a handler function.
 type Route struct {
-	Type    string         // Type specifies the type of the route (e.g., "static", "dynamic").
+	Type    string         // Type specifies the type of the route (e.g., "normal", "dynamic", "wildcard").
 	Handler RequestHandler // Handler is the function that handles the route.
 }
 
@@ -42,10 +59,10 @@ func (g *Goster) Use(path string, m ...RequestHandler) {
 	g.Middleware[path] = m
 }
 
-// TemplateDir extends the engine's file paths with the specified directory `d`,
-// which is joined to Engine.Config.BaseStaticDir (default is the execution path of the program).
+// TemplateDir makes the engine read templates like .html and .gohtml files from the directory `d`, replacing
+// any templates loaded before. Relative paths are resolved against the working directory first and then against
+// the directory of the executable.
 //
-// This instructs the engine where to look for template files like .html, .gohtml.
 // If the directory doesn't exist, it will return an appropriate error.
 func (g *Goster) TemplateDir(d string) (err error) {
 	err = engine.SetTemplateDir(d)
@@ -57,20 +74,82 @@ func (g *Goster) TemplateDir(d string) (err error) {
 	return
 }
 
-// StaticDir sets the directory from which static files like .css, .js, etc are served.
+// TemplateFS makes the engine read templates from fsys instead of a directory on disk, replacing any templates
+// loaded before. Templates are named by their path in fsys, so with
 //
-// It integrates the specified directory into the server's static file handling
-// by invoking AddStaticDir on the Routes collection.
+//	//go:embed templates
+//	var templates embed.FS
 //
-// If an error occurs during this process, the error is printed to the standard error output.
-// The function returns the error encountered, if any.
-func (g *Goster) StaticDir(dir string) (err error) {
+// use fs.Sub(templates, "templates") to render "home.html" instead of "templates/home.html".
+func (g *Goster) TemplateFS(fsys fs.FS) (err error) {
+	err = engine.SetTemplateFS(fsys)
+
+	if err != nil {
+		LogError(err.Error(), g.Logger)
+	}
+
+	return
+}
+
+// DevMode turns development mode on or off. In development mode, changes to the template directory
+// (new, edited or deleted templates) are picked up on the next render without restarting the server.
+// The directory is watched with inotify where available and polled otherwise.
+//
+// Outside of development mode templates are parsed once and never change.
+func (g *Goster) DevMode(on bool) {
+	engine.Config.DevMode = on
+	if !on {
+		engine.templates.closeWatcher()
+	}
+}
+
+// StaticDir sets the directory from which static files like .css, .js, etc are served. The files are served
+// under the URL path `dir`, so with g.StaticDir("static") the file static/css/app.css is served at /static/css/app.css.
+// Relative paths are resolved the same way as in TemplateDir.
+//
+// Options like directory listings or a fallback file for single-page apps can be given with opts:
+//
+//	g.StaticDir("dist", goster.StaticOptions{Fallback: "index.html"})
+//
+// If the directory doesn't exist, or an error occurs during this process, the error is logged and returned.
+func (g *Goster) StaticDir(dir string, opts ...StaticOptions) (err error) {
 	err = engine.SetStaticDir(dir)
 	if err != nil {
 		LogError(err.Error(), g.Logger)
+		return
+	}
+
+	return g.serveStatic(dir, os.DirFS(engine.Config.StaticDir), opts...)
+}
+
+// StaticFS serves the files of fsys as static files under the URL path prefix, so that they can be embedded
+// in the binary:
+//
+//	//go:embed static
+//	var static embed.FS
+//
+//	g.StaticFS("/static", static) // static/css/app.css is served at /static/static/css/app.css
+//
+// Use fs.Sub to serve a subdirectory of fsys. Options can be given with opts, like in StaticDir. StaticFS can be
+// called once for every prefix, each mount serving its own file system.
+func (g *Goster) StaticFS(prefix string, fsys fs.FS, opts ...StaticOptions) (err error) {
+	return g.serveStatic(prefix, fsys, opts...)
+}
+
+// serveStatic registers the routes of the static files of fsys under prefix.
+func (g *Goster) serveStatic(prefix string, fsys fs.FS, opts ...StaticOptions) (err error) {
+	var options StaticOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+
+	cleanPath(&prefix)
+	// the first mount is the default one of Asset, later ones don't silently take its place
+	if engine.Config.StaticPrefix == "" {
+		engine.Config.StaticPrefix = prefix
 	}
 
-	err = g.Routes.prepareStaticRoutes(dir)
+	err = g.Routes.prepareStaticRoutes(prefix, fsys, options)
 	if err != nil {
 		return fmt.Errorf("could not prepare routes for static files: %s", err)
 	}
@@ -78,125 +157,238 @@ func (g *Goster) StaticDir(dir string) (err error) {
 	return
 }
 
-// Start starts listening for incoming requests on the specified port (e.g., ":8080").
+// Start starts listening for incoming requests on the specified port (e.g., ":8080"). It returns once Shutdown
+// has been called, and exits the program if the server fails. It can be called again after Shutdown to serve anew.
 func (g *Goster) Start(p string) {
 	g.cleanUp()
-	LogInfo("LISTENING ON http://127.0.0.1"+p, g.Logger)
-	log.Fatal(http.ListenAndServe(p, g))
+	LogInfo("listening", g.Logger, "addr", "http://127.0.0.1"+p)
+	g.serve(p, func(srv *http.Server) error { return srv.ListenAndServe() })
 }
 
+// StartTLS is like Start, but serves HTTPS with the certificate and key in certFile and keyFile.
 func (g *Goster) StartTLS(addr string, certFile string, keyFile string) {
 	g.cleanUp()
-	LogInfo("LISTENING ON https://127.0.0.1"+addr, g.Logger)
-	log.Fatal(http.ListenAndServeTLS(addr, certFile, keyFile, g))
+	LogInfo("listening", g.Logger, "addr", "https://127.0.0.1"+addr)
+	g.serve(addr, func(srv *http.Server) error { return srv.ListenAndServeTLS(certFile, keyFile) })
+}
+
+// Shutdown stops the server started by Start or StartTLS gracefully. Readiness (see MountHealth) starts failing
+// right away, then after Health().DrainDelay the server stops accepting connections and waits for the active
+// requests to finish, until ctx is done. If no server is running yet (e.g. Start was called in a goroutine that
+// hasn't gotten to it), the next call to Start or StartTLS returns right away instead of serving:
+//
+//	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+//	defer stop()
+//	go g.Start(":8080")
+//	<-ctx.Done()
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+//	defer cancel()
+//	if err := g.Shutdown(ctx); err != nil {
+//		log.Println(err)
+//	}
+func (g *Goster) Shutdown(ctx context.Context) error {
+	g.health.shuttingDown.Store(true)
+	LogInfo("shutting down", g.Logger)
+
+	if g.health.DrainDelay > 0 {
+		select {
+		case <-time.After(g.health.DrainDelay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	g.serverMu.Lock()
+	srv := g.server
+	// Start may not have gotten to creating its server yet (e.g. with `go g.Start(...)`), it mustn't serve once it does
+	g.shutdownPending = srv == nil
+	g.serverMu.Unlock()
+	if srv == nil {
+		return nil
+	}
+	return srv.Shutdown(ctx)
+}
+
+// serve runs listen with a new server for addr until it's shut down. If Shutdown was called while no server was running,
+// it returns right away instead. A server that was shut down can be started again, so the shutdown of the previous one
+// no longer counts against readiness.
+func (g *Goster) serve(addr string, listen func(srv *http.Server) error) {
+	srv := &http.Server{Addr: addr, Handler: g}
+	g.serverMu.Lock()
+	if g.shutdownPending {
+		g.shutdownPending = false
+		g.serverMu.Unlock()
+		LogInfo("not serving, the server was shut down before it started", g.Logger, "addr", addr)
+		return
+	}
+	g.server = srv
+	g.health.shuttingDown.Store(false)
+	g.serverMu.Unlock()
+
+	defer func() {
+		g.serverMu.Lock()
+		if g.server == srv {
+			g.server = nil
+		}
+		g.serverMu.Unlock()
+	}()
+
+	err := listen(srv)
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
 }
 
 // ServeHTTP is the handler for incoming HTTP requests to the server.
 // It parses the request, manages routing, and is required to implement the http.Handler interface.
 func (g *Goster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	ctx := Ctx{
-		Request:  r,
-		Response: Response{w},
-		Meta: Meta{
-			Query: make(map[string]string),
-			Path:  make(map[string]string),
-		},
-	}
-	// Parse the URL and extract query parameters into the Meta struct
+	ctx := acquireCtx(g, w, r)
+	defer releaseCtx(ctx)
+
 	urlPath := ctx.Request.URL.EscapedPath()
 	method := ctx.Request.Method
-	DefaultHeader(&ctx)
 
-	// Validate the route based on the HTTP method and URL
-	status := g.validateRoute(method, urlPath)
-	if status != http.StatusOK {
-		ctx.Response.WriteHeader(status)
-		return
+	if g.metrics != nil {
+		g.metrics.inFlight.Add(1)
+		defer g.metrics.observe(ctx)
 	}
 
-	// Construct a normal route from URL path if it matches a specific dynamic route
-	for routePath, route := range g.Routes[method] {
-		if route.Type != "dynamic" {
-			continue
-		}
+	// Query params are parsed lazily, the first time they're requested
+	ctx.Meta.Query.reset(r.URL.RawQuery)
 
-		if matchesDynamicRoute(urlPath, routePath) {
-			ctx.Meta.ParseDynamicPath(urlPath, routePath)
-			err := g.Routes.New(method, urlPath, route.Handler)
-			if err != nil {
-				_ = fmt.Errorf("route %s is duplicate", urlPath) // TODO: it is duplicate, handle
-			}
-			break
-		}
+	// Find the route based on the HTTP method and URL, parsing the dynamic path segments if any
+	route, routePath, status := g.matchRoute(ctx, method, urlPath)
+	if status != http.StatusOK {
+		// global middleware still runs, so that access logs and the like see unmatched requests too
+		ctx.handlers = append(ctx.handlers[:0], g.Middleware["*"]...)
+		ctx.handlers = append(ctx.handlers, func(ctx *Ctx) error {
+			g.errorHandler()(ctx, NewProblem(status, ""))
+			return nil
+		})
+		ctx.index = -1
+		ctx.Next()
+		logRequest(ctx, g, nil)
+		return
 	}
 
-	// Parses query params if any and adds them to query map
-	ctx.Meta.ParseQueryParams(r.URL.String())
+	g.launchHandler(ctx, route, routePath, urlPath)
 
-	// Execute global middleware handlers
-	for _, middleware := range g.Middleware["*"] {
-		err := middleware(&ctx)
-		if err != nil {
-			LogError(fmt.Sprintf("error occured while running global middleware: %s", err.Error()), g.Logger)
-		}
+	// make sure the implicit 200 of net/http is reflected in the response
+	if !ctx.Response.Committed() {
+		ctx.Response.WriteHeader(http.StatusOK)
 	}
-	logRequest(&ctx, g, nil) // TODO: streamline builtin middleware
-
-	g.launchHandler(&ctx, method, urlPath)
+	logRequest(ctx, g, nil) // TODO: streamline builtin middleware
 }
 
 // ------------------------------------------Private Methods--------------------------------------------------- //
 
-// launchHandler launches the necessary handler for the incoming request based on the route.
-func (g *Goster) launchHandler(ctx *Ctx, method, urlPath string) {
+// launchHandler launches the handler of route for the incoming request.
+// The global middleware run first, followed by the route-specific middleware and finally the route handler.
+func (g *Goster) launchHandler(ctx *Ctx, route Route, routePath, urlPath string) {
 	cleanPath(&urlPath)
-	route := g.Routes[method][urlPath]
-	defer func() {
-		err := route.Handler(ctx)
-		// TODO: figure out what to do with handler error
-		if err != nil {
-			LogError(err.Error(), g.Logger)
-		}
-	}()
-	// Run all route-specific middleware defined by the user
-	for _, rh := range g.Middleware[urlPath] {
-		err := rh(ctx)
-		if err != nil {
-			LogError(fmt.Sprintf("error occured while running middleware: %s", err.Error()), g.Logger)
-		}
+
+	ctx.route = routePath
+	ctx.handlers = append(ctx.handlers[:0], g.Middleware["*"]...)
+	ctx.handlers = append(ctx.handlers, g.Middleware[routePath]...)
+	if routePath != urlPath {
+		ctx.handlers = append(ctx.handlers, g.Middleware[urlPath]...)
 	}
+	ctx.handlers = append(ctx.handlers, route.Handler)
+	ctx.index = -1
+	ctx.Next()
 }
 
-// validateRoute checks if the route "reqURL" exists inside the `g.Routes` collection
-// and under the method "reqMethod" so that the following expression evaluates to true:
-//
-//	if _, exists := g.Routes[m][u]; exists {
-//		// some code
-//	}
+// errorHandler returns the error handler of g, falling back to DefaultErrorHandler if none is set.
+func (g *Goster) errorHandler() ErrorHandler {
+	if g.ErrorHandler == nil {
+		return DefaultErrorHandler
+	}
+
+	return g.ErrorHandler
+}
+
+// matchRoute looks for the route that matches "urlPath" under the method "method" inside the `g.Routes` collection.
+// Routes whose path matches exactly take precedence over dynamic routes. If a dynamic route matches,
+// the values of its dynamic segments are stored in ctx.Meta.Path.
 //
-// If "reqURL" exists but not under the method "reqMethod", then the status `http.StatusMethodNotAllowed` is returned
+// If "urlPath" matches a route but not under the method "method", then the status `http.StatusMethodNotAllowed` is returned
 //
-// If "reqURL" doesn't exist then the status `http.StatusNotFound` is returned
-func (g *Goster) validateRoute(method, urlPath string) int {
+// If "urlPath" doesn't match any route then the status `http.StatusNotFound` is returned
+func (g *Goster) matchRoute(ctx *Ctx, method, urlPath string) (route Route, routePath string, status int) {
 	cleanPath(&urlPath)
+	// HEAD requests are answered by the GET routes, net/http leaves the body out of the response
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+	if route, exists := g.Routes[method][urlPath]; exists {
+		return route, urlPath, http.StatusOK
+	}
+
+	for routePath, route := range g.Routes[method] {
+		if route.Type == "dynamic" && ctx.Meta.Path.match(urlPath, routePath) {
+			return route, routePath, http.StatusOK
+		}
+	}
+
+	// wildcard routes come last, and the one with the longest prefix wins
+	if wildcardPath := g.matchWildcard(method, urlPath); wildcardPath != "" {
+		ctx.Meta.Path.match(urlPath, wildcardPath)
+		return g.Routes[method][wildcardPath], wildcardPath, http.StatusOK
+	}
+
+	if g.routeExists(urlPath) {
+		return route, "", http.StatusMethodNotAllowed
+	}
+
+	return route, "", http.StatusNotFound
+}
+
+// routeExists checks if "urlPath" matches a route under any method
+func (g *Goster) routeExists(urlPath string) bool {
+	var p Path
 	for m := range g.Routes {
-		_, exists := g.Routes[m][urlPath]
-		if exists && m == method {
-			return http.StatusOK
-		} else if exists && m != method {
-			return http.StatusMethodNotAllowed
+		if _, exists := g.Routes[m][urlPath]; exists {
+			return true
+		}
+		for routePath, route := range g.Routes[m] {
+			if route.Type == "dynamic" && p.match(urlPath, routePath) {
+				return true
+			}
+		}
+		if g.matchWildcard(m, urlPath) != "" {
+			return true
 		}
 	}
 
-	return http.StatusNotFound
+	return false
 }
 
-func (g *Goster) cleanUp() {
-	if engine.Config.BaseTemplateDir == "" {
-		LogInfo("No specified template directory. Defaulting to `templates/`...", g.Logger)
-		err := engine.SetTemplateDir("templates")
-		if err != nil {
-			LogError(err.Error(), g.Logger)
+// matchWildcard returns the path of the wildcard route of `method` with the longest prefix that matches urlPath,
+// or an empty string if none does
+func (g *Goster) matchWildcard(method, urlPath string) (wildcardPath string) {
+	var p Path
+	for routePath, route := range g.Routes[method] {
+		if route.Type == "wildcard" && len(routePath) > len(wildcardPath) && p.match(urlPath, routePath) {
+			wildcardPath = routePath
 		}
+		p = p[:0]
+	}
+
+	return
+}
+
+func (g *Goster) cleanUp() {
+	// templates read from an fs.FS have no BaseTemplateDir, but they're a choice just as much as a directory
+	if engine.Config.BaseTemplateDir != "" || engine.templateFS != nil {
+		return
+	}
+
+	// without a `templates/` directory the server simply has no templates, which isn't worth an error on every start
+	err := engine.SetTemplateDir("templates")
+	if err != nil {
+		g.Logger.Debug("no template directory specified and no `templates/` directory found", "error", err)
+		return
 	}
+	LogInfo("No specified template directory. Defaulting to `templates/`...", g.Logger)
 }
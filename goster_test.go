@@ -1,8 +1,13 @@
 package goster
 
 import (
-	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"testing/fstest"
 )
 
 type IsDynamicRouteCase struct {
@@ -94,60 +99,159 @@ func TestIsDynamicRoute(t *testing.T) {
 type TemplateDirMatch struct {
 	name        string
 	givenPath   string
-	exectedPath string
+	expectedErr bool
+}
+
+// chdirTemp changes the working directory to a temporary directory containing the given files for the duration of the test
+func chdirTemp(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	return dir
 }
 
 func TestTemplateDir(t *testing.T) {
 	g := NewServer()
+	keepTemplateConfig(t)
+	dir := chdirTemp(t, map[string]string{"templates/home.html": "<h1>{{.}}</h1>"})
 
 	testCases := []TemplateDirMatch{
-		{
-			name:        "Test 1",
-			givenPath:   "/templates",
-			exectedPath: "",
-		},
-		// {
-		// 	name:        "Test 2",
-		// 	givenPath:   "/static/templates/",
-		// 	exectedPath: "",
-		// },
+		{name: "Relative to working directory", givenPath: "templates"},
+		{name: "Relative with leading '/'", givenPath: "/templates"},
+		{name: "Absolute", givenPath: filepath.Join(dir, "templates")},
+		{name: "Missing directory", givenPath: "missing_templates", expectedErr: true},
 	}
 
 	failedCases := make(map[int]TemplateDirMatch, 0)
-	for _, tmpl := range testCases {
-		err := g.TemplateDir(tmpl.givenPath)
-		if err != nil {
-			t.Error(err)
+	for i, c := range testCases {
+		err := g.TemplateDir(c.givenPath)
+		_, recorded := engine.templatePath("home.html")
+		if (err != nil) != c.expectedErr || (!c.expectedErr && !recorded) {
+			failedCases[i] = c
+			t.Errorf("FAILED [%d] - %s: err %v, recorded %t", i, c.name, err, recorded)
+		} else {
+			t.Logf("PASSED [%d] - %s\n", i, c.name)
 		}
 	}
 
+	if _, err := os.Stat(filepath.Join(dir, "missing_templates")); !os.IsNotExist(err) {
+		t.Errorf("expected the missing template dir not to be created")
+	}
+
 	t.Logf("TOTAL CASES: %d\n", len(testCases))
 	t.Logf("FAILED CASES: %d\n", len(failedCases))
 }
 
 func TestStaticDir(t *testing.T) {
 	g := NewServer()
+	chdirTemp(t, map[string]string{"static_dir/css/app.css": "body{}"})
 
 	testCases := []TemplateDirMatch{
-		{
-			name:        "Test 1",
-			givenPath:   "/static",
-			exectedPath: "",
-		},
+		{name: "Relative to working directory", givenPath: "static_dir"},
+		{name: "Missing directory", givenPath: "missing_static", expectedErr: true},
 	}
 
 	failedCases := make(map[int]TemplateDirMatch, 0)
-	for _, tmpl := range testCases {
-		err := g.StaticDir(tmpl.givenPath)
-		if err != nil {
-			t.Error("could not set templates dir")
+	for i, c := range testCases {
+		err := g.StaticDir(c.givenPath)
+		if (err != nil) != c.expectedErr {
+			failedCases[i] = c
+			t.Errorf("FAILED [%d] - %s: err %v", i, c.name, err)
+		} else {
+			t.Logf("PASSED [%d] - %s\n", i, c.name)
 		}
+	}
 
-		for route := range g.Routes["GET"] {
-			fmt.Printf("Route %s\n", route)
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/static_dir/css/app.css", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "body{}" || rec.Header().Get("Content-Type") != "text/css; charset=utf-8" {
+		t.Errorf("expected /static_dir/css/app.css to be served, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	t.Logf("TOTAL CASES: %d\n", len(testCases))
+	t.Logf("FAILED CASES: %d\n", len(failedCases))
+}
+
+func TestTemplateAndStaticFS(t *testing.T) {
+	g := NewServer()
+	keepTemplateConfig(t)
+	fsys := fstest.MapFS{
+		"templates/layouts/base.html": {Data: []byte(`<main>{{block "content" .}}{{end}}</main>`)},
+		"templates/home.html":         {Data: []byte(`{{define "content"}}Hello {{.}}{{end}}`)},
+		"public/js/app.js":            {Data: []byte("alert(1)")},
+	}
+
+	templates, _ := fs.Sub(fsys, "templates")
+	if err := g.TemplateFS(templates); err != nil {
+		t.Fatal(err)
+	}
+	public, _ := fs.Sub(fsys, "public")
+	if err := g.StaticFS("/embedded", public); err != nil {
+		t.Fatal(err)
+	}
+	_ = g.Get("/fs/home", func(ctx *Ctx) error {
+		return ctx.Render("home.html", "embed", "layouts/base.html")
+	})
+
+	testCases := []struct {
+		name         string
+		url          string
+		expectedBody string
+	}{
+		{"Template from fs.FS", "/fs/home", "<main>Hello embed</main>"},
+		{"Static file from fs.FS", "/embedded/js/app.js", "alert(1)"},
+	}
+
+	failedCases := make(map[int]string, 0)
+	for i, c := range testCases {
+		rec := httptest.NewRecorder()
+		g.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, c.url, nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != c.expectedBody {
+			failedCases[i] = c.name
+			t.Errorf("FAILED [%d] - %s: got %d %q", i, c.name, rec.Code, rec.Body.String())
+		} else {
+			t.Logf("PASSED [%d] - %s\n", i, c.name)
 		}
 	}
 
 	t.Logf("TOTAL CASES: %d\n", len(testCases))
 	t.Logf("FAILED CASES: %d\n", len(failedCases))
 }
+
+func TestCleanUpKeepsTemplateFS(t *testing.T) {
+	g := NewServer()
+	keepTemplateConfig(t)
+	chdirTemp(t, map[string]string{"templates/home.html": "from disk"})
+
+	if err := g.TemplateFS(fstest.MapFS{"home.html": {Data: []byte("from fs")}}); err != nil {
+		t.Fatal(err)
+	}
+	g.cleanUp()
+
+	_ = g.Get("/clean-up/home", func(ctx *Ctx) error {
+		return ctx.Render("home.html", nil)
+	})
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/clean-up/home", nil))
+	if rec.Body.String() != "from fs" || engine.Config.BaseTemplateDir != "" {
+		t.Errorf("expected the embedded templates to be kept, got %q from %q", rec.Body.String(), engine.Config.BaseTemplateDir)
+	}
+}
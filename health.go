@@ -0,0 +1,251 @@
+package goster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultHealthCheckTimeout is the time a health check gets to finish when its options don't set one.
+const DefaultHealthCheckTimeout = 5 * time.Second
+
+// The statuses of health checks and reports.
+const (
+	HealthPass = "pass" // HealthPass means that everything is fine
+	HealthWarn = "warn" // HealthWarn means that a non-critical check failed, the server still serves requests
+	HealthFail = "fail" // HealthFail means that a critical check failed or the server is shutting down
+)
+
+// ErrShuttingDown is the error readiness reports while the server shuts down.
+var ErrShuttingDown = errors.New("goster: server is shutting down")
+
+// HealthCheck reports whether a dependency of the server, like a database, is usable. It should give up once ctx is done.
+type HealthCheck func(ctx context.Context) error
+
+// CheckOptions configure a health check.
+type CheckOptions struct {
+	Timeout     time.Duration // Timeout is the time the check gets to finish before it fails, DefaultHealthCheckTimeout if 0
+	CacheFor    time.Duration // CacheFor reuses the result of the check for that long, so that frequent probes don't overload the dependency
+	NonCritical bool          // NonCritical checks only turn the report to "warn" when they fail, instead of failing it
+	Liveness    bool          // Liveness checks also run for /livez, keep them to failures a restart fixes (like a deadlock)
+}
+
+// CheckResult is the result of a single health check.
+type CheckResult struct {
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	Critical   bool      `json:"critical"`
+	DurationMs float64   `json:"duration_ms"`
+	CheckedAt  time.Time `json:"checked_at"`
+}
+
+// HealthReport is the result of running the health checks, as served by the health endpoints.
+type HealthReport struct {
+	Status string                 `json:"status"`
+	Error  string                 `json:"error,omitempty"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// Health keeps the health checks of the server, see Goster.Health.
+type Health struct {
+	// DrainDelay is how long Goster.Shutdown keeps serving requests after readiness starts failing,
+	// so that load balancers notice and stop sending new requests before the server stops accepting them.
+	DrainDelay time.Duration
+
+	mu           sync.RWMutex
+	checks       []*healthCheck
+	shuttingDown atomic.Bool
+}
+
+// healthCheck is a registered health check along with its last result
+type healthCheck struct {
+	name    string
+	check   HealthCheck
+	options CheckOptions
+
+	mu     sync.Mutex
+	last   CheckResult
+	cached bool
+}
+
+// Health returns the health checks of the server:
+//
+//	g.Health().AddCheck("database", func(ctx context.Context) error {
+//		return db.PingContext(ctx)
+//	}, goster.CheckOptions{Timeout: time.Second})
+//
+// Mount the /healthz, /readyz and /livez endpoints with MountHealth.
+func (g *Goster) Health() *Health {
+	return g.health
+}
+
+// AddCheck adds the health check `check` under name. Checks are critical unless their options say otherwise:
+// when a critical check fails, the server reports that it isn't ready.
+//
+// It panics if a check with the same name already exists.
+func (h *Health) AddCheck(name string, check HealthCheck, opts ...CheckOptions) {
+	var options CheckOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultHealthCheckTimeout
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, c := range h.checks {
+		if c.name == name {
+			panic(fmt.Sprintf("goster: health check %q already exists", name))
+		}
+	}
+	h.checks = append(h.checks, &healthCheck{name: name, check: check, options: options})
+}
+
+// ShuttingDown reports whether Goster.Shutdown has been called, in which case readiness fails.
+func (h *Health) ShuttingDown() bool {
+	return h.shuttingDown.Load()
+}
+
+// Check runs the health checks concurrently and reports their results. With liveness, only the checks added
+// with CheckOptions.Liveness run.
+func (h *Health) Check(ctx context.Context, liveness bool) HealthReport {
+	h.mu.RLock()
+	checks := make([]*healthCheck, 0, len(h.checks))
+	for _, c := range h.checks {
+		if !liveness || c.options.Liveness {
+			checks = append(checks, c)
+		}
+	}
+	h.mu.RUnlock()
+
+	results := make([]CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c *healthCheck) {
+			defer wg.Done()
+			results[i] = c.run(ctx)
+		}(i, c)
+	}
+	wg.Wait()
+
+	report := HealthReport{Status: HealthPass, Checks: make(map[string]CheckResult, len(checks))}
+	for i, c := range checks {
+		r := results[i]
+		report.Checks[c.name] = r
+		if r.Status == HealthPass {
+			continue
+		}
+		if r.Critical {
+			report.Status = HealthFail
+		} else if report.Status == HealthPass {
+			report.Status = HealthWarn
+		}
+	}
+	return report
+}
+
+// run runs the check with its timeout, or returns its cached result
+func (c *healthCheck) run(parent context.Context) CheckResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cached && time.Since(c.last.CheckedAt) < c.options.CacheFor {
+		return c.last
+	}
+
+	ctx, cancel := context.WithTimeout(parent, c.options.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		done <- c.check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// checks that ignore ctx are left to finish on their own
+		err = ctx.Err()
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = fmt.Errorf("timed out after %s", c.options.Timeout)
+		}
+	}
+
+	result := CheckResult{Status: HealthPass, Critical: !c.options.NonCritical, DurationMs: float64(time.Since(start).Microseconds()) / 1000, CheckedAt: start}
+	if err != nil {
+		result.Status = HealthFail
+		result.Error = err.Error()
+	}
+
+	// a probe that went away says nothing about the dependency, so its failure isn't kept for the next probes
+	if parent.Err() != nil {
+		return result
+	}
+
+	c.last, c.cached = result, c.options.CacheFor > 0
+	return result
+}
+
+// MountHealth registers the health endpoints under prefix ("" for the root), guarded by middleware if any:
+//
+//	prefix/healthz   runs every check
+//	prefix/readyz    runs every check and fails while the server shuts down, for load balancers and readiness probes
+//	prefix/livez     runs the liveness checks only, for liveness probes that restart the process
+//
+// They respond with 200 when the report's status is "pass" or "warn" and 503 when it's "fail", along with the
+// result of every check as JSON:
+//
+//	{"status":"warn","checks":{"cache":{"status":"fail","error":"connection refused","critical":false,...}}}
+func (g *Goster) MountHealth(prefix string, middleware ...RequestHandler) error {
+	cleanPath(&prefix)
+
+	endpoints := []struct {
+		path      string
+		liveness  bool
+		readiness bool
+	}{
+		{"healthz", false, false},
+		{"readyz", false, true},
+		{"livez", true, false},
+	}
+
+	for _, e := range endpoints {
+		liveness, readiness := e.liveness, e.readiness
+		p := path.Join("/", prefix, e.path)
+		err := g.Get(p, func(ctx *Ctx) error {
+			report := g.health.Check(ctx.Context(), liveness)
+			if readiness && g.health.ShuttingDown() {
+				report.Status = HealthFail
+				report.Error = ErrShuttingDown.Error()
+			}
+
+			status := http.StatusOK
+			if report.Status == HealthFail {
+				status = http.StatusServiceUnavailable
+			}
+			ctx.Response.Header().Set("Cache-Control", "no-store")
+			return ctx.encode(JSONEncoder{}, "application/json", status, report)
+		})
+		if err != nil {
+			return err
+		}
+		if len(middleware) > 0 {
+			g.Use(p, middleware...)
+		}
+	}
+	return nil
+}
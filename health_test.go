@@ -0,0 +1,245 @@
+package goster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type HealthCase struct {
+	name     string
+	checks   map[string]HealthCheck
+	options  map[string]CheckOptions
+	endpoint string
+	status   int
+	report   string            // report is the expected status of the report
+	results  map[string]string // results are the expected statuses of the checks
+}
+
+func TestHealth(t *testing.T) {
+	g := NewServer()
+	health := g.health
+	defer func() { g.health = health }()
+
+	if err := g.MountHealth("/health-test"); err != nil {
+		t.Fatal(err)
+	}
+
+	pass := func(ctx context.Context) error { return nil }
+	fail := func(ctx context.Context) error { return errors.New("connection refused") }
+	slow := func(ctx context.Context) error { <-ctx.Done(); return ctx.Err() }
+	stuck := func(ctx context.Context) error { time.Sleep(time.Second); return nil }
+
+	testCases := []HealthCase{
+		{"No checks", nil, nil, "healthz", http.StatusOK, HealthPass, map[string]string{}},
+		{"Passing checks", map[string]HealthCheck{"db": pass, "cache": pass}, nil, "healthz", http.StatusOK, HealthPass,
+			map[string]string{"db": HealthPass, "cache": HealthPass}},
+		{"Critical failure", map[string]HealthCheck{"db": fail, "cache": pass}, nil, "healthz", http.StatusServiceUnavailable, HealthFail,
+			map[string]string{"db": HealthFail, "cache": HealthPass}},
+		{"Non-critical failure", map[string]HealthCheck{"db": pass, "cache": fail}, map[string]CheckOptions{"cache": {NonCritical: true}},
+			"readyz", http.StatusOK, HealthWarn, map[string]string{"db": HealthPass, "cache": HealthFail}},
+		{"Timeout", map[string]HealthCheck{"db": slow}, map[string]CheckOptions{"db": {Timeout: 10 * time.Millisecond}},
+			"readyz", http.StatusServiceUnavailable, HealthFail, map[string]string{"db": HealthFail}},
+		{"Check ignoring its context", map[string]HealthCheck{"db": stuck}, map[string]CheckOptions{"db": {Timeout: 10 * time.Millisecond}},
+			"readyz", http.StatusServiceUnavailable, HealthFail, map[string]string{"db": HealthFail}},
+		{"Panic", map[string]HealthCheck{"db": func(ctx context.Context) error { panic("boom") }}, nil,
+			"healthz", http.StatusServiceUnavailable, HealthFail, map[string]string{"db": HealthFail}},
+		{"Liveness checks only", map[string]HealthCheck{"db": fail, "loop": pass}, map[string]CheckOptions{"loop": {Liveness: true}},
+			"livez", http.StatusOK, HealthPass, map[string]string{"loop": HealthPass}},
+	}
+
+	failedCases := make(map[int]HealthCase, 0)
+	for i, c := range testCases {
+		g.health = &Health{}
+		for name, check := range c.checks {
+			g.health.AddCheck(name, check, c.options[name])
+		}
+
+		rec := httptest.NewRecorder()
+		g.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health-test/"+c.endpoint, nil))
+
+		var report HealthReport
+		err := json.Unmarshal(rec.Body.Bytes(), &report)
+		failed := err != nil || rec.Code != c.status || report.Status != c.report || len(report.Checks) != len(c.results) ||
+			rec.Header().Get("Cache-Control") != "no-store"
+		for name, status := range c.results {
+			failed = failed || report.Checks[name].Status != status
+		}
+
+		if failed {
+			failedCases[i] = c
+			t.Errorf("FAILED [%d] - %s: expected %d with %q, got %d: %s", i, c.name, c.status, c.report, rec.Code, rec.Body.String())
+		} else {
+			t.Logf("PASSED [%d] - %s\n", i, c.name)
+		}
+	}
+
+	t.Logf("TOTAL CASES: %d\n", len(testCases))
+	t.Logf("FAILED CASES: %d\n", len(failedCases))
+}
+
+func TestHealthCache(t *testing.T) {
+	h := &Health{}
+	var calls atomic.Int32
+	h.AddCheck("db", func(ctx context.Context) error {
+		calls.Add(1)
+		return nil
+	}, CheckOptions{CacheFor: time.Hour})
+	h.AddCheck("uncached", func(ctx context.Context) error {
+		calls.Add(10)
+		return nil
+	})
+
+	first := h.Check(context.Background(), false)
+	second := h.Check(context.Background(), false)
+	if calls.Load() != 21 {
+		t.Errorf("expected the cached check to run once and the other twice, got %d calls", calls.Load())
+	}
+	if !first.Checks["db"].CheckedAt.Equal(second.Checks["db"].CheckedAt) {
+		t.Errorf("expected the cached result to be reused, got %v and %v", first.Checks["db"].CheckedAt, second.Checks["db"].CheckedAt)
+	}
+
+	// a probe whose client went away doesn't leave its failure in the cache
+	h.AddCheck("canceled", func(ctx context.Context) error {
+		return ctx.Err()
+	}, CheckOptions{CacheFor: time.Hour})
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if report := h.Check(canceled, false); report.Checks["canceled"].Status != HealthFail {
+		t.Errorf("expected the check to fail for a canceled probe, got %+v", report.Checks["canceled"])
+	}
+	if report := h.Check(context.Background(), false); report.Checks["canceled"].Status != HealthPass {
+		t.Errorf("expected the check to run again after a canceled probe, got %+v", report.Checks["canceled"])
+	}
+
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(string), `"db" already exists`) {
+			t.Errorf("expected adding a check twice to panic, got %v", r)
+		}
+	}()
+	h.AddCheck("db", func(ctx context.Context) error { return nil })
+}
+
+func TestShutdown(t *testing.T) {
+	g := NewServer()
+	health := g.health
+	defer func() {
+		g.health = health
+		g.server, g.shutdownPending = nil, false
+	}()
+	g.health = &Health{DrainDelay: 20 * time.Millisecond}
+	// Start sets up the default template directory
+	keepTemplateConfig(t)
+
+	if err := g.MountHealth("/shutdown-test"); err != nil {
+		t.Fatal(err)
+	}
+
+	// start starts the server and waits until it has replaced the previous one
+	start := func() (stopped chan struct{}) {
+		g.serverMu.Lock()
+		previous := g.server
+		g.serverMu.Unlock()
+
+		stopped = startAsync(g)
+
+		deadline := time.Now().Add(time.Second)
+		for {
+			g.serverMu.Lock()
+			started := g.server != previous
+			g.serverMu.Unlock()
+			if started || time.Now().After(deadline) {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	// wait waits for Shutdown and then Start to return
+	wait := func(shutdown <-chan error, stopped chan struct{}) {
+		select {
+		case err := <-shutdown:
+			if err != nil {
+				t.Errorf("expected a graceful shutdown, got %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Shutdown didn't return")
+		}
+		select {
+		case <-stopped:
+		case <-time.After(time.Second):
+			t.Fatal("Start didn't return after Shutdown")
+		}
+	}
+
+	stopped := start()
+	shutdown := shutdownAsync(g)
+
+	// readiness fails while the server drains, liveness doesn't
+	time.Sleep(5 * time.Millisecond)
+	for _, c := range []struct {
+		endpoint string
+		status   int
+	}{
+		{"readyz", http.StatusServiceUnavailable},
+		{"healthz", http.StatusOK},
+		{"livez", http.StatusOK},
+	} {
+		rec := httptest.NewRecorder()
+		g.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/shutdown-test/"+c.endpoint, nil))
+		if rec.Code != c.status {
+			t.Errorf("expected %s to respond with %d while shutting down, got %d: %s", c.endpoint, c.status, rec.Code, rec.Body.String())
+		}
+	}
+
+	wait(shutdown, stopped)
+
+	// a server started after a shutdown is ready again
+	stopped = start()
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/shutdown-test/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected readyz to respond with %d after starting again, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	wait(shutdownAsync(g), stopped)
+
+	// a shutdown before the server started keeps it from serving, but not the one started after it
+	if err := g.Shutdown(context.Background()); err != nil {
+		t.Errorf("expected Shutdown without a server to succeed, got %v", err)
+	}
+	select {
+	case <-startAsync(g):
+	case <-time.After(time.Second):
+		t.Fatal("expected Start to return right away after an early shutdown")
+	}
+	stopped = start()
+	g.serverMu.Lock()
+	started := g.server != nil
+	g.serverMu.Unlock()
+	if !started {
+		t.Error("expected the server started after an early shutdown to serve")
+	}
+	wait(shutdownAsync(g), stopped)
+}
+
+// startAsync starts g in the background and closes the returned channel once Start returns
+func startAsync(g *Goster) chan struct{} {
+	stopped := make(chan struct{})
+	go func() {
+		g.Start("127.0.0.1:0")
+		close(stopped)
+	}()
+	return stopped
+}
+
+// shutdownAsync shuts g down in the background and sends the result of Shutdown on the returned channel
+func shutdownAsync(g *Goster) <-chan error {
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- g.Shutdown(context.Background()) }()
+	return shutdown
+}
@@ -0,0 +1,371 @@
+package goster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLogBufferSize is the number of entries the log buffer of a server keeps.
+const DefaultLogBufferSize = 1000
+
+// LogEntry is a log record kept by a LogBuffer.
+type LogEntry struct {
+	Time    time.Time      `json:"time"`
+	Level   slog.Level     `json:"level"`
+	Message string         `json:"msg"`
+	Attrs   map[string]any `json:"attrs,omitempty"` // Attrs holds the attributes of the record. Attributes of groups are keyed by "group.key"
+}
+
+// LogFilter selects the entries returned by LogBuffer.Entries. The zero value selects every entry.
+type LogFilter struct {
+	MinLevel slog.Leveler // MinLevel is the lowest level of the selected entries, every level if nil
+	Since    time.Time    // Since leaves out the entries older than it, unless it's zero
+	Until    time.Time    // Until leaves out the entries newer than it, unless it's zero
+	Path     string       // Path selects the entries whose "path" attribute starts with it, e.g. the request records of "/api"
+	Limit    int          // Limit is the maximum number of entries, the most recent ones are kept. 0 means no limit
+}
+
+// match reports whether e is selected by f
+func (f LogFilter) match(e LogEntry) bool {
+	if f.MinLevel != nil && e.Level < f.MinLevel.Level() {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && e.Time.After(f.Until) {
+		return false
+	}
+	if f.Path != "" {
+		p, _ := e.Attrs["path"].(string)
+		if !strings.HasPrefix(p, f.Path) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// LogBuffer keeps the most recent log entries in memory. Once it's full, every new entry replaces the oldest one,
+// so its memory use stays the same no matter how long the server runs. It's safe for concurrent use.
+type LogBuffer struct {
+	mu          sync.Mutex
+	entries     []LogEntry // entries is used as a ring, next being the position of the next entry
+	next        int
+	full        bool
+	subscribers map[chan LogEntry]struct{}
+}
+
+// NewLogBuffer creates a LogBuffer that keeps up to `capacity` entries (DefaultLogBufferSize if it's not positive).
+func NewLogBuffer(capacity int) *LogBuffer {
+	if capacity <= 0 {
+		capacity = DefaultLogBufferSize
+	}
+
+	return &LogBuffer{entries: make([]LogEntry, capacity), subscribers: make(map[chan LogEntry]struct{})}
+}
+
+// Add adds e to the buffer, dropping the oldest entry if the buffer is full, and sends it to the subscribers.
+func (b *LogBuffer) Add(e LogEntry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.entries[b.next] = e
+	b.next++
+	if b.next == len(b.entries) {
+		b.next = 0
+		b.full = true
+	}
+
+	for ch := range b.subscribers {
+		// slow subscribers miss entries rather than holding up the server
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Entries returns the entries selected by filter, from the oldest to the newest.
+func (b *LogBuffer) Entries(filter LogFilter) []LogEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ordered := b.entries[:b.next]
+	if b.full {
+		ordered = append(b.entries[b.next:len(b.entries):len(b.entries)], b.entries[:b.next]...)
+	}
+
+	entries := []LogEntry{}
+	for _, e := range ordered {
+		if filter.match(e) {
+			entries = append(entries, e)
+		}
+	}
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[len(entries)-filter.Limit:]
+	}
+
+	return entries
+}
+
+// Len returns the number of entries in the buffer.
+func (b *LogBuffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.full {
+		return len(b.entries)
+	}
+	return b.next
+}
+
+// Subscribe returns a channel that receives every entry added to the buffer from now on, along with a function
+// that stops the subscription. Entries are dropped if the channel isn't drained fast enough.
+func (b *LogBuffer) Subscribe() (<-chan LogEntry, func()) {
+	ch := make(chan LogEntry, 64)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+		})
+	}
+}
+
+// Handler returns a slog.Handler that adds the records it handles to the buffer before passing them on to next.
+// It's how the logger of a server fills Goster.Logs; wrap the handler of your own logger with it to keep doing so:
+//
+//	g.Logger = slog.New(g.Logs.Handler(myHandler))
+func (b *LogBuffer) Handler(next slog.Handler) slog.Handler {
+	return &logBufferHandler{buf: b, next: next}
+}
+
+// logBufferHandler is the slog.Handler returned by LogBuffer.Handler
+type logBufferHandler struct {
+	buf    *LogBuffer
+	next   slog.Handler
+	attrs  map[string]any // attrs are the attributes added with WithAttrs
+	prefix string         // prefix is the key prefix of the groups opened with WithGroup
+}
+
+func (h *logBufferHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *logBufferHandler) Handle(ctx context.Context, r slog.Record) error {
+	attrs := make(map[string]any, len(h.attrs)+r.NumAttrs())
+	for k, v := range h.attrs {
+		attrs[k] = v
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		addLogAttr(attrs, h.prefix, a)
+		return true
+	})
+
+	h.buf.Add(LogEntry{Time: r.Time, Level: r.Level, Message: r.Message, Attrs: attrs})
+	return h.next.Handle(ctx, r)
+}
+
+func (h *logBufferHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.attrs = make(map[string]any, len(h.attrs)+len(attrs))
+	for k, v := range h.attrs {
+		h2.attrs[k] = v
+	}
+	for _, a := range attrs {
+		addLogAttr(h2.attrs, h.prefix, a)
+	}
+	h2.next = h.next.WithAttrs(attrs)
+	return &h2
+}
+
+func (h *logBufferHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	h2 := *h
+	h2.prefix = h.prefix + name + "."
+	h2.next = h.next.WithGroup(name)
+	return &h2
+}
+
+// addLogAttr adds a to attrs under its key prefixed with prefix, flattening groups.
+// Values are converted to types that encode to JSON sensibly (e.g. errors to their message).
+func addLogAttr(attrs map[string]any, prefix string, a slog.Attr) {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		groupPrefix := prefix
+		if a.Key != "" {
+			groupPrefix += a.Key + "."
+		}
+		for _, ga := range v.Group() {
+			addLogAttr(attrs, groupPrefix, ga)
+		}
+		return
+	}
+	if a.Key == "" {
+		return
+	}
+
+	var value any
+	switch v.Kind() {
+	case slog.KindDuration:
+		value = v.Duration().String()
+	case slog.KindTime:
+		value = v.Time()
+	case slog.KindAny:
+		switch t := v.Any().(type) {
+		case error:
+			value = t.Error()
+		case fmt.Stringer:
+			value = t.String()
+		default:
+			value = t
+		}
+	default:
+		value = v.Any()
+	}
+	attrs[prefix+a.Key] = value
+}
+
+// RecentLogs returns the entries of the log buffer of the server that are selected by filter, from the oldest to the newest.
+//
+//	errors := g.RecentLogs(goster.LogFilter{MinLevel: slog.LevelError, Since: time.Now().Add(-time.Hour)})
+func (g *Goster) RecentLogs(filter LogFilter) []LogEntry {
+	if g.Logs == nil {
+		return []LogEntry{}
+	}
+
+	return g.Logs.Entries(filter)
+}
+
+// MountLogs registers a GET route at path that responds with the recent log entries as JSON. The entries can be
+// filtered with the query parameters `level` (e.g. "warn"), `since` and `until` (RFC 3339), `path` and `limit`.
+// With `follow=1` (or "Accept: text/event-stream"), the selected entries and every new one are streamed as
+// server-sent events until the client disconnects.
+//
+// Logs often contain sensitive data, so guard the route with middleware:
+//
+//	g.MountLogs("/admin/logs", requireAdmin)
+func (g *Goster) MountLogs(path string, middleware ...RequestHandler) error {
+	err := g.Get(path, func(ctx *Ctx) error {
+		return serveLogs(ctx, g.Logs)
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(middleware) > 0 {
+		g.Use(path, middleware...)
+	}
+	return nil
+}
+
+// serveLogs sends the entries of buf selected by the query of the request, streaming new entries if asked to
+func serveLogs(ctx *Ctx, buf *LogBuffer) error {
+	if buf == nil {
+		return NewProblem(http.StatusNotFound, "the server has no log buffer")
+	}
+
+	filter, err := logFilterFromQuery(ctx)
+	if err != nil {
+		return NewProblem(http.StatusBadRequest, err.Error())
+	}
+
+	follow, _ := ctx.Query.Get("follow")
+	if follow == "" || follow == "0" || follow == "false" {
+		if !strings.Contains(ctx.Request.Header.Get("Accept"), "text/event-stream") {
+			return ctx.JSON(buf.Entries(filter))
+		}
+	}
+
+	// subscribe before reading the recent entries, so that nothing is missed in between
+	entries, unsubscribe := buf.Subscribe()
+	defer unsubscribe()
+
+	h := ctx.Response.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	ctx.Response.WriteHeader(http.StatusOK)
+
+	var last time.Time
+	send := func(e LogEntry) error {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if _, err = fmt.Fprintf(&ctx.Response, "data: %s\n\n", b); err != nil {
+			return err
+		}
+		last = e.Time
+		return nil
+	}
+
+	for _, e := range buf.Entries(filter) {
+		if err := send(e); err != nil {
+			return err
+		}
+	}
+	ctx.Response.Flush()
+
+	// new entries aren't limited, only filtered
+	filter.Limit = 0
+	for {
+		select {
+		case <-ctx.Context().Done():
+			return nil
+		case e := <-entries:
+			if !e.Time.After(last) || !filter.match(e) {
+				continue
+			}
+			if err := send(e); err != nil {
+				return err
+			}
+			ctx.Response.Flush()
+		}
+	}
+}
+
+// logFilterFromQuery builds a LogFilter from the query parameters of the request
+func logFilterFromQuery(ctx *Ctx) (filter LogFilter, err error) {
+	if level, _ := ctx.Query.Get("level"); level != "" {
+		var l slog.Level
+		if err = l.UnmarshalText([]byte(level)); err != nil {
+			return filter, fmt.Errorf("invalid level `%s`", level)
+		}
+		filter.MinLevel = l
+	}
+	if since, _ := ctx.Query.Get("since"); since != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			return filter, fmt.Errorf("invalid time `%s`", since)
+		}
+	}
+	if until, _ := ctx.Query.Get("until"); until != "" {
+		if filter.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			return filter, fmt.Errorf("invalid time `%s`", until)
+		}
+	}
+	if limit, _ := ctx.Query.Get("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit < 0 {
+			return filter, fmt.Errorf("invalid limit `%s`", limit)
+		}
+	}
+	filter.Path, _ = ctx.Query.Get("path")
+
+	return filter, nil
+}
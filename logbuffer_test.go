@@ -0,0 +1,220 @@
+package goster
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type LogFilterCase struct {
+	name     string
+	filter   LogFilter
+	expected []string
+}
+
+func TestLogBuffer(t *testing.T) {
+	buf := NewLogBuffer(4)
+	start := time.Date(2025, 3, 7, 12, 0, 0, 0, time.UTC)
+
+	messages := []struct {
+		level slog.Level
+		path  string
+	}{
+		{slog.LevelInfo, "/a"},  // dropped once the buffer wraps
+		{slog.LevelDebug, "/a"}, // dropped once the buffer wraps
+		{slog.LevelInfo, "/api/users"},
+		{slog.LevelError, "/api/books"},
+		{slog.LevelWarn, "/home"},
+		{slog.LevelInfo, "/api/users/42"},
+	}
+	for i, m := range messages {
+		buf.Add(LogEntry{Time: start.Add(time.Duration(i) * time.Minute), Level: m.level, Message: m.path, Attrs: map[string]any{"path": m.path}})
+	}
+
+	if buf.Len() != 4 {
+		t.Fatalf("expected the buffer to hold 4 entries, got %d", buf.Len())
+	}
+
+	testCases := []LogFilterCase{
+		{"All", LogFilter{}, []string{"/api/users", "/api/books", "/home", "/api/users/42"}},
+		{"Min level", LogFilter{MinLevel: slog.LevelWarn}, []string{"/api/books", "/home"}},
+		{"Since", LogFilter{Since: start.Add(4 * time.Minute)}, []string{"/home", "/api/users/42"}},
+		{"Until", LogFilter{Until: start.Add(3 * time.Minute)}, []string{"/api/users", "/api/books"}},
+		{"Path", LogFilter{Path: "/api/users"}, []string{"/api/users", "/api/users/42"}},
+		{"Limit keeps the newest", LogFilter{Path: "/api", Limit: 2}, []string{"/api/books", "/api/users/42"}},
+	}
+
+	failedCases := make(map[int]LogFilterCase, 0)
+	for i, c := range testCases {
+		got := []string{}
+		for _, e := range buf.Entries(c.filter) {
+			got = append(got, e.Message)
+		}
+
+		if strings.Join(got, ",") != strings.Join(c.expected, ",") {
+			failedCases[i] = c
+			t.Errorf("FAILED [%d] - %s: expected %v, got %v", i, c.name, c.expected, got)
+		} else {
+			t.Logf("PASSED [%d] - %s\n", i, c.name)
+		}
+	}
+
+	t.Logf("TOTAL CASES: %d\n", len(testCases))
+	t.Logf("FAILED CASES: %d\n", len(failedCases))
+}
+
+func TestLogBufferHandler(t *testing.T) {
+	buf := NewLogBuffer(10)
+	logger := slog.New(buf.Handler(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelInfo})))
+
+	logger.Debug("dropped")
+	logger.With("service", "api").WithGroup("req").Error("failed", "error", errors.New("boom"), "latency", 2*time.Millisecond, slog.Group("user", "id", 42))
+
+	entries := buf.Entries(LogFilter{})
+	if len(entries) != 1 {
+		t.Fatalf("expected only the enabled record, got %v", entries)
+	}
+
+	e := entries[0]
+	if e.Message != "failed" || e.Level != slog.LevelError || e.Attrs["service"] != "api" || e.Attrs["req.error"] != "boom" ||
+		e.Attrs["req.latency"] != "2ms" || e.Attrs["req.user.id"] != int64(42) {
+		t.Errorf("unexpected entry %+v", e)
+	}
+}
+
+func TestRecentLogs(t *testing.T) {
+	g := NewServer()
+	logger := g.Logger
+	defer func() { g.Logger = logger }()
+	g.Logger = slog.New(g.Logs.Handler(slog.NewTextHandler(io.Discard, nil)))
+
+	_ = g.Get("/recent-logs/ok", func(ctx *Ctx) error {
+		return nil
+	})
+	_ = g.Get("/recent-logs/fail", func(ctx *Ctx) error {
+		return errors.New("boom")
+	})
+
+	since := time.Now()
+	for _, url := range []string{"/recent-logs/ok", "/recent-logs/fail", "/recent-logs/ok"} {
+		g.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, url, nil))
+	}
+
+	if entries := g.RecentLogs(LogFilter{Since: since, Path: "/recent-logs/"}); len(entries) != 4 {
+		t.Errorf("expected 3 request records and 1 error record, got %v", entries)
+	}
+	entries := g.RecentLogs(LogFilter{Since: since, MinLevel: slog.LevelError})
+	if len(entries) != 1 || entries[0].Attrs["error"] != "boom" {
+		t.Errorf("expected the error record, got %v", entries)
+	}
+}
+
+func TestMountLogs(t *testing.T) {
+	g := NewServer()
+	logger := g.Logger
+	defer func() { g.Logger = logger }()
+	g.Logger = slog.New(g.Logs.Handler(slog.NewTextHandler(io.Discard, nil)))
+
+	_ = g.MountLogs("/admin/logs", func(ctx *Ctx) error {
+		if ctx.Request.Header.Get("Authorization") != "secret" {
+			ctx.Response.WriteHeader(http.StatusUnauthorized)
+			return errors.New("unauthorized")
+		}
+		return nil
+	})
+
+	since := url.QueryEscape(time.Now().Format(time.RFC3339Nano))
+	g.Logger.Warn("mount-logs warning", "path", "/mount-logs")
+	g.Logger.Info("mount-logs info", "path", "/mount-logs")
+
+	request := func(url string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		req.Header.Set("Authorization", "secret")
+		rec := httptest.NewRecorder()
+		g.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/logs", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected the middleware to guard the logs, got %d", rec.Code)
+	}
+
+	rec = request("/admin/logs?level=warn&path=/mount-logs&since=" + since)
+	entries := []LogEntry{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &entries); err != nil || len(entries) != 1 || entries[0].Message != "mount-logs warning" {
+		t.Errorf("expected the warning as JSON, got %q", rec.Body.String())
+	}
+
+	if rec := request("/admin/logs?level=loud"); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected an invalid level to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestMountLogsFollow(t *testing.T) {
+	g := NewServer()
+	logger := g.Logger
+	defer func() { g.Logger = logger }()
+	g.Logger = slog.New(g.Logs.Handler(slog.NewTextHandler(io.Discard, nil)))
+
+	_ = g.MountLogs("/admin/logs-follow")
+	g.Logger.Info("before follow", "path", "/follow")
+
+	srv := httptest.NewServer(g)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/admin/logs-follow?follow=1&path=/follow&limit=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %q", resp.Header.Get("Content-Type"))
+	}
+
+	events := make(chan LogEntry)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			data, found := strings.CutPrefix(scanner.Text(), "data: ")
+			if !found {
+				continue
+			}
+			var e LogEntry
+			if json.Unmarshal([]byte(data), &e) == nil {
+				events <- e
+			}
+		}
+		close(events)
+	}()
+
+	next := func() LogEntry {
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for a log event")
+		}
+		return LogEntry{}
+	}
+
+	if e := next(); e.Message != "before follow" {
+		t.Errorf("expected the recent entry first, got %+v", e)
+	}
+
+	g.Logger.Info("ignored", "path", "/elsewhere")
+	g.Logger.Info("after follow", "path", "/follow")
+	if e := next(); e.Message != "after follow" {
+		t.Errorf("expected the new entry to be streamed, got %+v", e)
+	}
+}
@@ -1,20 +1,42 @@
 package goster
 
 import (
-	"log"
+	"io"
+	"log/slog"
 )
 
-// Supply msg with a string value to be logged to the console and a logger
-func LogInfo(msg string, logger *log.Logger) {
-	logger.Printf("INFO - %s\n", msg)
+// LogFormat is the format of the loggers created by NewLogger.
+type LogFormat int
+
+const (
+	LogFormatText LogFormat = iota // LogFormatText writes records as key=value pairs, which are easy to read in a terminal
+	LogFormatJSON                  // LogFormatJSON writes every record as a JSON object on its own line, for log pipelines
+)
+
+// NewLogger creates a logger that writes the records of at least `level` to w in the given format.
+// Pass a *slog.LevelVar as level to be able to change it while the server is running.
+//
+//	g.Logger = goster.NewLogger(os.Stderr, goster.LogFormatJSON, slog.LevelWarn)
+func NewLogger(w io.Writer, format LogFormat, level slog.Leveler) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+	if format == LogFormatJSON {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+// Supply msg with a string value to be logged at the info level by logger, along with any attributes as key/value pairs or slog.Attr
+func LogInfo(msg string, logger *slog.Logger, args ...any) {
+	logger.Info(msg, args...)
 }
 
-// Supply msg with a string value to be logged to the console and a logger
-func LogWarning(msg string, logger *log.Logger) {
-	logger.Printf("WARN - %s\n", msg)
+// Supply msg with a string value to be logged at the warning level by logger, along with any attributes as key/value pairs or slog.Attr
+func LogWarning(msg string, logger *slog.Logger, args ...any) {
+	logger.Warn(msg, args...)
 }
 
-// Supply msg with a string value to be logged to the console and a logger
-func LogError(msg string, logger *log.Logger) {
-	logger.Printf("ERROR - %s\n", msg)
+// Supply msg with a string value to be logged at the error level by logger, along with any attributes as key/value pairs or slog.Attr
+func LogError(msg string, logger *slog.Logger, args ...any) {
+	logger.Error(msg, args...)
 }
@@ -0,0 +1,71 @@
+package goster
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestLog(t *testing.T) {
+	g := NewServer()
+	logger := g.Logger
+	defer func() { g.Logger = logger }()
+
+	var buf bytes.Buffer
+	level := new(slog.LevelVar)
+	g.Logger = NewLogger(&buf, LogFormatJSON, level)
+
+	_ = g.Get("/log/ok", func(ctx *Ctx) error {
+		ctx.Text("ok")
+		return nil
+	})
+	_ = g.Get("/log/fail", func(ctx *Ctx) error {
+		return errors.New("boom")
+	})
+
+	records := func(url string) []map[string]any {
+		buf.Reset()
+		g.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, url, nil))
+
+		recs := []map[string]any{}
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			if line == "" {
+				continue
+			}
+			rec := map[string]any{}
+			if err := json.Unmarshal([]byte(line), &rec); err != nil {
+				t.Fatalf("expected JSON records, got %q", line)
+			}
+			recs = append(recs, rec)
+		}
+		return recs
+	}
+
+	recs := records("/log/ok?page=2")
+	if len(recs) != 1 {
+		t.Fatalf("expected a single record, got %v", recs)
+	}
+	rec := recs[0]
+	if rec["msg"] != "request" || rec["level"] != "INFO" || rec["method"] != "GET" || rec["path"] != "/log/ok" ||
+		rec["status"] != float64(200) || rec["bytes"] != float64(2) || rec["query"] != "page=2" || rec["latency"] == nil {
+		t.Errorf("unexpected request record %v", rec)
+	}
+
+	recs = records("/log/fail")
+	if len(recs) != 2 || recs[0]["level"] != "ERROR" || recs[0]["error"] != "boom" || recs[1]["status"] != float64(500) {
+		t.Errorf("expected an error record followed by the request record, got %v", recs)
+	}
+
+	level.Set(slog.LevelWarn)
+	if recs := records("/log/ok"); len(recs) != 0 {
+		t.Errorf("expected info records to be dropped, got %v", recs)
+	}
+	if recs := records("/log/fail"); len(recs) != 1 || recs[0]["level"] != "ERROR" {
+		t.Errorf("expected only the error record, got %v", recs)
+	}
+}
@@ -1,7 +1,7 @@
 package goster
 
 import (
-	"regexp"
+	"net/url"
 	"strings"
 )
 
@@ -15,70 +15,178 @@ type DynamicPath struct {
 	value string
 }
 
-type Params map[string]string
+// Params holds the query parameters of a request. They are parsed lazily, the first time one of them is requested.
+type Params struct {
+	raw    string        // raw is the query string without the leading '?'
+	parsed bool          // parsed reports whether raw has been parsed into values
+	values []DynamicPath // values holds the parsed parameters in the order they appear in the query string
+}
 
-type Path map[string]string
+// Path holds the values of the dynamic segments of a route (e.g. `:id` in "/users/:id").
+type Path []DynamicPath
 
-// Get tries to find if `id` is in the URL's Query Params
+// Get tries to find if `id` is in the URL's Query Params. If `id` appears more than once, the last value is returned.
 //
 // If the specified `id` isn't found `exists` will be false
 func (p *Params) Get(id string) (value string, exists bool) {
-	value, exists = (*p)[id]
+	p.parse()
+	for i := len(p.values) - 1; i >= 0; i-- {
+		if p.values[i].path == id {
+			return p.values[i].value, true
+		}
+	}
+
 	return
 }
 
+// Map returns the query parameters as a map. If a parameter appears more than once, the last value is kept.
+func (p *Params) Map() map[string]string {
+	p.parse()
+	m := make(map[string]string, len(p.values))
+	for _, v := range p.values {
+		m[v.path] = v.value
+	}
+
+	return m
+}
+
+// reset discards the parsed parameters and sets the query string to be parsed to raw.
+func (p *Params) reset(raw string) {
+	p.raw = strings.Trim(raw, "/?")
+	p.parsed = false
+	p.values = p.values[:0]
+}
+
+// parse splits the raw query string into values. It only runs once for every query string.
+func (p *Params) parse() {
+	if p.parsed {
+		return
+	}
+	p.parsed = true
+
+	params := p.raw
+	for len(params) > 0 {
+		var param string
+		param, params, _ = strings.Cut(params, "&")
+		if len(param) == 0 {
+			continue
+		}
+
+		key, value, _ := strings.Cut(param, "=")
+		p.values = append(p.values, DynamicPath{
+			path:  unescapeQuery(key),
+			value: unescapeQuery(value),
+		})
+	}
+}
+
 // Get tries to find if `id` is in the URL's as a Dynamic Path Identifier
 //
 // If the specified `id` isn't found `exists` will be false
 func (p *Path) Get(id string) (value string, exists bool) {
-	value, exists = (*p)[id]
+	for _, dp := range *p {
+		if dp.path == id {
+			return dp.value, true
+		}
+	}
+
 	return
 }
 
-// Pass in a `url` and see if there're parameters in it
-//
-// If there're, ParseQueryParams will construct a Params struct and populate Meta.Query.Params
+// Map returns the values of the dynamic segments as a map, like ctx.Path used to be before it became a slice.
+func (p *Path) Map() map[string]string {
+	m := make(map[string]string, len(*p))
+	for _, dp := range *p {
+		m[dp.path] = dp.value
+	}
+
+	return m
+}
+
+// match checks whether urlPath matches the route path routePath segment by segment and, if it does,
+// appends the values of the dynamic segments of routePath to p. Both paths are expected to be clean.
 //
-// If there aren't any, ParseQueryParams will return
+// A wildcard segment (e.g. "*filepath") can only be the last segment of routePath and matches the rest of urlPath,
+// including any '/' in it, or nothing at all.
 //
-// The `url` string reference that is passed in will have the parameters stripped in either case
-func (m *Meta) ParseQueryParams(url string) {
-	paramValues := make(map[string]string, 0)
-	paramPattern := regexp.MustCompile(`\?.+(\/)?`)
-
-	defer func() {
-		m.Query = paramValues
-	}()
+// If they don't match, p is left untouched and `matched` will be false
+func (p *Path) match(urlPath, routePath string) (matched bool) {
+	start := len(*p)
+	for {
+		routeSeg, routeRest, routeMore := strings.Cut(routePath, "/")
+		urlSeg, urlRest, urlMore := strings.Cut(urlPath, "/")
+
+		if strings.HasPrefix(routeSeg, "*") {
+			rest, _, _ := strings.Cut(urlPath, "?")
+			*p = append(*p, DynamicPath{
+				path:  routeSeg[1:],
+				value: rest,
+			})
+			return true
+		}
 
-	params := paramPattern.FindString(url)
-	params = strings.Trim(params, "/?")
+		if strings.HasPrefix(routeSeg, ":") {
+			urlSeg, _, _ = strings.Cut(urlSeg, "?")
+			*p = append(*p, DynamicPath{
+				path:  routeSeg[1:],
+				value: urlSeg,
+			})
+		} else if routeSeg != urlSeg {
+			// static segment doesn't match
+			*p = (*p)[:start]
+			return false
+		}
 
-	if len(params) == 0 {
-		return
-	}
+		// the wildcard matches an empty rest of the path too
+		if routeMore && !urlMore && strings.HasPrefix(routeRest, "*") && !strings.Contains(routeRest, "/") {
+			*p = append(*p, DynamicPath{
+				path:  routeRest[1:],
+				value: "",
+			})
+			return true
+		}
 
-	for _, v := range strings.Split(params, "&") {
-		query := strings.Split(v, "=")
+		// the paths have a different number of segments
+		if routeMore != urlMore {
+			*p = (*p)[:start]
+			return false
+		}
 
-		if len(query) == 1 {
-			paramValues[query[0]] = ""
-			continue
+		if !routeMore {
+			return true
 		}
 
-		paramValues[query[0]] = query[1]
+		routePath, urlPath = routeRest, urlRest
 	}
 }
 
+// Pass in a `url` and see if there're parameters in it
+//
+// If there're, ParseQueryParams will populate Meta.Query with them. The parameters are parsed lazily,
+// the first time they're requested.
+//
+// If there aren't any, Meta.Query will be empty
+func (m *Meta) ParseQueryParams(url string) {
+	_, query, _ := strings.Cut(url, "?")
+	m.Query.reset(query)
+}
+
 func (m *Meta) ParseDynamicPath(url, urlPath string) {
 	cleanPath(&url)
 	cleanPath(&urlPath)
-	dynamicPaths, isDynamic := matchDynamicPath(url, urlPath)
+	m.Path.match(url, urlPath)
+}
 
-	if !isDynamic {
-		return
+// unescapeQuery decodes the percent-encoded query component s. It only allocates if s is actually encoded.
+func unescapeQuery(s string) string {
+	if !strings.ContainsAny(s, "%+") {
+		return s
 	}
 
-	for _, dynamicPath := range dynamicPaths {
-		m.Path[dynamicPath.path] = dynamicPath.value
+	unescaped, err := url.QueryUnescape(s)
+	if err != nil {
+		return s
 	}
+
+	return unescaped
 }
@@ -51,6 +51,15 @@ func TestParseUrl(t *testing.T) {
 			},
 			shouldFail: false,
 		},
+		{
+			name: "5",
+			url:  "/var/home?name=dimitris%20pouris&q=a+b",
+			expectedQueryParams: map[string]string{
+				"name": "dimitris pouris",
+				"q":    "a b",
+			},
+			shouldFail: false,
+		},
 	}
 
 	failedCases := make(map[int]struct {
@@ -58,11 +67,9 @@ func TestParseUrl(t *testing.T) {
 		ParseUrlCase
 	}, 0)
 	for i, c := range testCases {
-		meta := Meta{
-			Query: make(map[string]string),
-		}
+		meta := Meta{}
 		meta.ParseQueryParams(c.url)
-		if (!maps.Equal(meta.Query, c.expectedQueryParams)) == !c.shouldFail {
+		if (!maps.Equal(meta.Query.Map(), c.expectedQueryParams)) == !c.shouldFail {
 			failedCases[i] = struct {
 				Meta
 				ParseUrlCase
@@ -77,9 +84,21 @@ func TestParseUrl(t *testing.T) {
 
 	for i, c := range failedCases {
 		t.Errorf("FAILED [%d] - %s\n", i, c.ParseUrlCase.name)
-		t.Errorf("Expected '%v' path, but got '%v'", c.expectedQueryParams, c.Query)
+		t.Errorf("Expected '%v' path, but got '%v'", c.expectedQueryParams, c.Query.Map())
 	}
 
 	t.Logf("TOTAL CASES: %d\n", len(testCases))
 	t.Logf("FAILED CASES: %d\n", len(failedCases))
 }
+
+func TestPathMap(t *testing.T) {
+	var p Path
+	if !p.match("users/42/books/7", "users/:uid/books/:bid") {
+		t.Fatal("expected the path to match")
+	}
+
+	expected := map[string]string{"uid": "42", "bid": "7"}
+	if !maps.Equal(p.Map(), expected) {
+		t.Errorf("expected %v, got %v", expected, p.Map())
+	}
+}
@@ -0,0 +1,338 @@
+package goster
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultLatencyBuckets are the upper bounds in seconds of the buckets of the request duration histogram.
+var DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// DefaultSizeBuckets are the upper bounds in bytes of the buckets of the response size histogram.
+var DefaultSizeBuckets = []float64{100, 1000, 10_000, 100_000, 1_000_000, 10_000_000}
+
+// unmatchedRoute is the route label of requests that didn't match any route. Route patterns start with '/', so it can't clash with one.
+const unmatchedRoute = "unmatched"
+
+// processStart is used for the process_start_time_seconds metric
+var processStart = time.Now()
+
+// MetricsOptions configure the metrics collected by Goster.MountMetrics.
+type MetricsOptions struct {
+	Namespace      string    // Namespace prefixes the names of the HTTP metrics, "goster" if empty
+	LatencyBuckets []float64 // LatencyBuckets are the buckets of the request duration histogram in seconds, DefaultLatencyBuckets if empty
+	SizeBuckets    []float64 // SizeBuckets are the buckets of the response size histogram in bytes, DefaultSizeBuckets if empty
+}
+
+// MountMetrics starts recording metrics for every request and serves them at path ("/metrics" if empty) in the Prometheus
+// text format, so that Prometheus can scrape them without any client library:
+//
+//	g.MountMetrics("/metrics")
+//
+// The following metrics are recorded, labeled by method and route pattern (e.g. "/users/:id" rather than "/users/42", so that
+// dynamic paths don't create a series each). Requests that don't match any route have the route label "unmatched".
+//
+//	goster_http_requests_total              counter of requests, also labeled by status
+//	goster_http_request_duration_seconds    histogram of the time it took to handle requests
+//	goster_http_response_size_bytes         histogram of the size of response bodies
+//	goster_http_requests_in_flight          gauge of the requests being handled
+//
+// Go runtime and process metrics (goroutines, memory, GC) are included too. Guard the endpoint with middleware through
+// Goster.Use if it shouldn't be public.
+func (g *Goster) MountMetrics(path string, opts ...MetricsOptions) error {
+	var options MetricsOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	if path == "" {
+		path = "/metrics"
+	}
+
+	m := newMetrics(options)
+	err := g.Get(path, func(ctx *Ctx) error {
+		ctx.Response.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, err := ctx.Response.Write(m.expose())
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	g.metrics = m
+	return nil
+}
+
+// Route returns the pattern of the route that matched the request, like "/users/:id", or "" if no route matched.
+func (c *Ctx) Route() string {
+	return c.route
+}
+
+// metrics holds the HTTP metrics of a server
+type metrics struct {
+	namespace      string
+	latencyBuckets []float64
+	sizeBuckets    []float64
+
+	inFlight atomic.Int64
+	mu       sync.RWMutex
+	series   map[seriesKey]*routeSeries
+}
+
+// seriesKey identifies the metrics of a route
+type seriesKey struct {
+	method string
+	route  string
+}
+
+// routeSeries holds the metrics of a route
+type routeSeries struct {
+	mu       sync.Mutex
+	statuses map[int]uint64 // statuses counts the requests by status code
+	duration *histogram
+	size     *histogram
+}
+
+func newMetrics(opts MetricsOptions) *metrics {
+	m := &metrics{namespace: opts.Namespace, latencyBuckets: opts.LatencyBuckets, sizeBuckets: opts.SizeBuckets, series: make(map[seriesKey]*routeSeries)}
+	if m.namespace == "" {
+		m.namespace = "goster"
+	}
+	if len(m.latencyBuckets) == 0 {
+		m.latencyBuckets = DefaultLatencyBuckets
+	}
+	if len(m.sizeBuckets) == 0 {
+		m.sizeBuckets = DefaultSizeBuckets
+	}
+	m.latencyBuckets = sortedBuckets(m.latencyBuckets)
+	m.sizeBuckets = sortedBuckets(m.sizeBuckets)
+
+	return m
+}
+
+// observe records the request of ctx once it has been handled
+func (m *metrics) observe(ctx *Ctx) {
+	m.inFlight.Add(-1)
+
+	key := seriesKey{method: metricsMethod(ctx.Request.Method), route: ctx.route}
+	if key.route == "" {
+		key.route = unmatchedRoute
+	}
+	status := ctx.Response.Status()
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	m.mu.RLock()
+	s, exists := m.series[key]
+	m.mu.RUnlock()
+	if !exists {
+		m.mu.Lock()
+		if s, exists = m.series[key]; !exists {
+			s = &routeSeries{statuses: make(map[int]uint64), duration: newHistogram(m.latencyBuckets), size: newHistogram(m.sizeBuckets)}
+			m.series[key] = s
+		}
+		m.mu.Unlock()
+	}
+
+	s.mu.Lock()
+	s.statuses[status]++
+	s.duration.observe(ctx.Response.Elapsed().Seconds())
+	s.size.observe(float64(ctx.Response.Written()))
+	s.mu.Unlock()
+}
+
+// metricsMethod returns the method label of method. Non-standard methods share the label "OTHER",
+// since clients can send anything as the method.
+func metricsMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
+
+// histogram counts observations in buckets. It's guarded by the mutex of its routeSeries.
+type histogram struct {
+	buckets []float64 // buckets are the upper bounds of the buckets, in ascending order
+	counts  []uint64  // counts are the observations of each bucket, not cumulative
+	count   uint64
+	sum     float64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+func (h *histogram) observe(v float64) {
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += v
+}
+
+// sortedBuckets returns a sorted copy of buckets without duplicates and +Inf, which every histogram has anyway
+func sortedBuckets(buckets []float64) []float64 {
+	sorted := make([]float64, 0, len(buckets))
+	for _, b := range buckets {
+		if !math.IsInf(b, 1) && !math.IsNaN(b) {
+			sorted = append(sorted, b)
+		}
+	}
+	sort.Float64s(sorted)
+
+	unique := sorted[:0]
+	for i, b := range sorted {
+		if i == 0 || b != sorted[i-1] {
+			unique = append(unique, b)
+		}
+	}
+	return unique
+}
+
+// expose returns the metrics in the Prometheus text exposition format
+func (m *metrics) expose() []byte {
+	m.mu.RLock()
+	keys := make([]seriesKey, 0, len(m.series))
+	for k := range m.series {
+		keys = append(keys, k)
+	}
+	m.mu.RUnlock()
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		return keys[i].method < keys[j].method
+	})
+
+	// copy the series so that requests aren't held up while the output is built
+	type snapshot struct {
+		key      seriesKey
+		statuses map[int]uint64
+		duration histogram
+		size     histogram
+	}
+	snapshots := make([]snapshot, len(keys))
+	for i, k := range keys {
+		m.mu.RLock()
+		s := m.series[k]
+		m.mu.RUnlock()
+
+		s.mu.Lock()
+		snap := snapshot{key: k, statuses: make(map[int]uint64, len(s.statuses)), duration: *s.duration, size: *s.size}
+		for status, n := range s.statuses {
+			snap.statuses[status] = n
+		}
+		snap.duration.counts = append([]uint64(nil), s.duration.counts...)
+		snap.size.counts = append([]uint64(nil), s.size.counts...)
+		s.mu.Unlock()
+		snapshots[i] = snap
+	}
+
+	var buf bytes.Buffer
+	name := m.namespace + "_http_requests_total"
+	writeMetricHeader(&buf, name, "counter", "Total number of HTTP requests by method, route and status.")
+	for _, s := range snapshots {
+		statuses := make([]int, 0, len(s.statuses))
+		for status := range s.statuses {
+			statuses = append(statuses, status)
+		}
+		sort.Ints(statuses)
+		for _, status := range statuses {
+			fmt.Fprintf(&buf, "%s{method=%s,route=%s,status=\"%d\"} %d\n", name, labelValue(s.key.method), labelValue(s.key.route), status, s.statuses[status])
+		}
+	}
+
+	name = m.namespace + "_http_request_duration_seconds"
+	writeMetricHeader(&buf, name, "histogram", "Time it took to handle HTTP requests, in seconds.")
+	for _, s := range snapshots {
+		writeHistogram(&buf, name, s.key, &s.duration)
+	}
+
+	name = m.namespace + "_http_response_size_bytes"
+	writeMetricHeader(&buf, name, "histogram", "Size of HTTP response bodies, in bytes.")
+	for _, s := range snapshots {
+		writeHistogram(&buf, name, s.key, &s.size)
+	}
+
+	name = m.namespace + "_http_requests_in_flight"
+	writeMetricHeader(&buf, name, "gauge", "Number of HTTP requests being handled.")
+	fmt.Fprintf(&buf, "%s %d\n", name, m.inFlight.Load())
+
+	writeRuntimeMetrics(&buf)
+	return buf.Bytes()
+}
+
+func writeMetricHeader(buf *bytes.Buffer, name, kind, help string) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func writeHistogram(buf *bytes.Buffer, name string, key seriesKey, h *histogram) {
+	labels := "method=" + labelValue(key.method) + ",route=" + labelValue(key.route)
+
+	var cumulative uint64
+	for i, bound := range h.buckets {
+		cumulative += h.counts[i]
+		fmt.Fprintf(buf, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, formatFloat(bound), cumulative)
+	}
+	fmt.Fprintf(buf, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+	fmt.Fprintf(buf, "%s_sum{%s} %s\n", name, labels, formatFloat(h.sum))
+	fmt.Fprintf(buf, "%s_count{%s} %d\n", name, labels, h.count)
+}
+
+// writeRuntimeMetrics writes the metrics of the Go runtime and the process, named like the ones of the Prometheus client library
+func writeRuntimeMetrics(buf *bytes.Buffer) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	gauge := func(name, help string, v float64) {
+		writeMetricHeader(buf, name, "gauge", help)
+		fmt.Fprintf(buf, "%s %s\n", name, formatFloat(v))
+	}
+	counter := func(name, help string, v float64) {
+		writeMetricHeader(buf, name, "counter", help)
+		fmt.Fprintf(buf, "%s %s\n", name, formatFloat(v))
+	}
+
+	writeMetricHeader(buf, "go_info", "gauge", "Information about the Go environment.")
+	fmt.Fprintf(buf, "go_info{version=%s} 1\n", labelValue(runtime.Version()))
+	gauge("go_goroutines", "Number of goroutines that currently exist.", float64(runtime.NumGoroutine()))
+	gauge("go_threads", "Number of OS threads created.", float64(pprof.Lookup("threadcreate").Count()))
+
+	writeMetricHeader(buf, "go_gc_duration_seconds", "summary", "Pause durations of the garbage collector.")
+	fmt.Fprintf(buf, "go_gc_duration_seconds_sum %s\ngo_gc_duration_seconds_count %d\n", formatFloat(float64(ms.PauseTotalNs)/1e9), ms.NumGC)
+
+	gauge("go_memstats_alloc_bytes", "Number of bytes allocated and still in use.", float64(ms.Alloc))
+	counter("go_memstats_alloc_bytes_total", "Total number of bytes allocated, even if freed.", float64(ms.TotalAlloc))
+	gauge("go_memstats_sys_bytes", "Number of bytes obtained from the system.", float64(ms.Sys))
+	counter("go_memstats_mallocs_total", "Total number of mallocs.", float64(ms.Mallocs))
+	counter("go_memstats_frees_total", "Total number of frees.", float64(ms.Frees))
+	gauge("go_memstats_heap_alloc_bytes", "Number of heap bytes allocated and still in use.", float64(ms.HeapAlloc))
+	gauge("go_memstats_heap_inuse_bytes", "Number of heap bytes that are in use.", float64(ms.HeapInuse))
+	gauge("go_memstats_heap_idle_bytes", "Number of heap bytes waiting to be used.", float64(ms.HeapIdle))
+	gauge("go_memstats_heap_objects", "Number of allocated objects.", float64(ms.HeapObjects))
+	gauge("go_memstats_stack_inuse_bytes", "Number of bytes in use by the stack allocator.", float64(ms.StackInuse))
+	gauge("go_memstats_next_gc_bytes", "Number of heap bytes when the next garbage collection will take place.", float64(ms.NextGC))
+	gauge("go_memstats_last_gc_time_seconds", "Number of seconds since 1970 of the last garbage collection.", float64(ms.LastGC)/1e9)
+	gauge("process_start_time_seconds", "Start time of the process since unix epoch in seconds.", float64(processStart.UnixNano())/1e9)
+}
+
+// labelValue quotes v as a label value, escaping backslashes, quotes and newlines
+func labelValue(v string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v) + `"`
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,95 @@
+package goster
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type MetricsCase struct {
+	name     string
+	expected string // expected is a line the exposition has to contain
+}
+
+func TestMetrics(t *testing.T) {
+	g := NewServer()
+	defer func() { g.metrics = nil }()
+
+	if err := g.MountMetrics("/metrics-test/metrics", MetricsOptions{LatencyBuckets: []float64{1, 0.5, 1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	routes := []string{}
+	_ = g.Get("/metrics-test/users/:id", func(ctx *Ctx) error {
+		routes = append(routes, ctx.Route())
+		ctx.Text("user")
+		return nil
+	})
+	_ = g.Post("/metrics-test/users/:id", func(ctx *Ctx) error {
+		ctx.Response.WriteHeader(http.StatusCreated)
+		return nil
+	})
+
+	for _, r := range []struct{ method, url string }{
+		{http.MethodGet, "/metrics-test/users/1"},
+		{http.MethodGet, "/metrics-test/users/2"},
+		{http.MethodPost, "/metrics-test/users/3"},
+		{http.MethodGet, "/metrics-test/nothing-here"},
+		{"BREW", "/metrics-test/users/1"},
+	} {
+		g.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(r.method, r.url, nil))
+	}
+
+	if strings.Join(routes, ",") != "/metrics-test/users/:id,/metrics-test/users/:id" {
+		t.Errorf("expected Ctx.Route to return the pattern, got %v", routes)
+	}
+
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics-test/metrics", nil))
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("expected the Prometheus content type, got %q", rec.Header().Get("Content-Type"))
+	}
+	exposition := rec.Body.String()
+
+	testCases := []MetricsCase{
+		{"Requests by route pattern", `goster_http_requests_total{method="GET",route="/metrics-test/users/:id",status="200"} 2`},
+		{"Requests by status", `goster_http_requests_total{method="POST",route="/metrics-test/users/:id",status="201"} 1`},
+		{"Unmatched requests", `goster_http_requests_total{method="GET",route="unmatched",status="404"} 1`},
+		{"Unknown methods", `goster_http_requests_total{method="OTHER",route="unmatched",status="405"} 1`},
+		{"Histogram buckets", `goster_http_request_duration_seconds_bucket{method="GET",route="/metrics-test/users/:id",le="0.5"} 2`},
+		{"Histogram +Inf bucket", `goster_http_request_duration_seconds_bucket{method="GET",route="/metrics-test/users/:id",le="+Inf"} 2`},
+		{"Histogram count", `goster_http_request_duration_seconds_count{method="GET",route="/metrics-test/users/:id"} 2`},
+		{"Response sizes", `goster_http_response_size_bytes_sum{method="GET",route="/metrics-test/users/:id"} 8`},
+		{"Small responses", `goster_http_response_size_bytes_bucket{method="GET",route="/metrics-test/users/:id",le="100"} 2`},
+		{"In flight", `goster_http_requests_in_flight 1`},
+		{"Type lines", `# TYPE goster_http_request_duration_seconds histogram`},
+		{"Runtime", `# TYPE go_goroutines gauge`},
+	}
+
+	failedCases := make(map[int]MetricsCase, 0)
+	for i, c := range testCases {
+		if !strings.Contains(exposition, c.expected+"\n") {
+			failedCases[i] = c
+			t.Errorf("FAILED [%d] - %s: %q is missing", i, c.name, c.expected)
+		} else {
+			t.Logf("PASSED [%d] - %s\n", i, c.name)
+		}
+	}
+
+	t.Logf("TOTAL CASES: %d\n", len(testCases))
+	t.Logf("FAILED CASES: %d\n", len(failedCases))
+
+	if t.Failed() {
+		t.Log(exposition)
+	}
+	if strings.Contains(exposition, "/metrics-test/users/1") {
+		t.Errorf("expected raw paths to stay out of the labels")
+	}
+}
+
+func TestLabelValue(t *testing.T) {
+	if got := labelValue("a\"b\\c\nd"); got != `"a\"b\\c\nd"` {
+		t.Errorf("unexpected escaping %s", got)
+	}
+}
@@ -1,18 +1,33 @@
 package goster
 
-func logRequest(c *Ctx, g *Goster, err error) {
-	m := c.Request.Method
-	u := c.Request.URL.String()
+import "log/slog"
 
+// logRequest records the outcome of a request once its handler has returned, with the method, path, status,
+// size and latency of the request as attributes. Nothing is recorded if the logger of g drops records of its level.
+func logRequest(c *Ctx, g *Goster, err error) {
+	level := slog.LevelInfo
 	if err != nil {
-		l := err.Error()
-		g.Logs = append(g.Logs, l)
-		LogError(l, g.Logger)
+		level = slog.LevelError
+	}
+
+	if !g.Logger.Enabled(c.Context(), level) {
 		return
 	}
-	l := "[" + m + "]" + " ON ROUTE " + u
-	g.Logs = append(g.Logs, l)
-	LogInfo(l, g.Logger)
+
+	attrs := []slog.Attr{
+		slog.String("method", c.Request.Method),
+		slog.String("path", c.Request.URL.Path),
+		slog.Int("status", c.Response.Status()),
+		slog.Int64("bytes", c.Response.Written()),
+		slog.Duration("latency", c.Response.Elapsed()),
+	}
+	if c.Request.URL.RawQuery != "" {
+		attrs = append(attrs, slog.String("query", c.Request.URL.RawQuery))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	c.Logger().LogAttrs(c.Context(), level, "request", attrs...)
 }
 
 // TODO: should be auth middleware
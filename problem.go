@@ -0,0 +1,213 @@
+package goster
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ProblemDetails is a machine-readable description of an error as defined by RFC 9457. It implements error,
+// so handlers can return it and have it rendered to the client by the default error handler:
+//
+//	return goster.NewProblem(http.StatusNotFound, "user 42 doesn't exist")
+type ProblemDetails struct {
+	Type       string         // Type is a URI reference that identifies the problem type. An empty Type means "about:blank".
+	Title      string         // Title is a short, human-readable summary of the problem type.
+	Status     int            // Status is the HTTP status code of the response.
+	Detail     string         // Detail is a human-readable explanation specific to this occurrence of the problem.
+	Instance   string         // Instance is a URI reference that identifies the specific occurrence of the problem.
+	Extensions map[string]any // Extensions are additional members that are rendered alongside the standard ones.
+}
+
+// NewProblem creates a ProblemDetails for the status code s with the given detail. The title is the status text of s.
+func NewProblem(s int, detail string) *ProblemDetails {
+	return &ProblemDetails{
+		Title:  http.StatusText(s),
+		Status: s,
+		Detail: detail,
+	}
+}
+
+// With sets the extension member key to value and returns p for chaining.
+func (p *ProblemDetails) With(key string, value any) *ProblemDetails {
+	if p.Extensions == nil {
+		p.Extensions = make(map[string]any)
+	}
+
+	p.Extensions[key] = value
+	return p
+}
+
+func (p *ProblemDetails) Error() string {
+	if p.Detail == "" {
+		return fmt.Sprintf("%d %s", p.Status, p.Title)
+	}
+
+	return fmt.Sprintf("%d %s: %s", p.Status, p.Title, p.Detail)
+}
+
+// MarshalJSON renders p as a JSON object with the extension members next to the standard ones.
+func (p *ProblemDetails) MarshalJSON() ([]byte, error) {
+	members := make(map[string]any, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		members[k] = v
+	}
+
+	if p.Type != "" {
+		members["type"] = p.Type
+	}
+	if p.Title != "" {
+		members["title"] = p.Title
+	}
+	if p.Status != 0 {
+		members["status"] = p.Status
+	}
+	if p.Detail != "" {
+		members["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		members["instance"] = p.Instance
+	}
+
+	return json.Marshal(members)
+}
+
+// MarshalXML renders p in the XML format described in appendix B of RFC 9457. Extension members are rendered
+// as elements in the order of their names, with their values formatted with fmt.
+func (p *ProblemDetails) MarshalXML(e *xml.Encoder, _ xml.StartElement) error {
+	start := xml.StartElement{Name: xml.Name{Space: "urn:ietf:rfc:7807", Local: "problem"}}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	members := [][2]string{{"type", p.Type}, {"title", p.Title}, {"detail", p.Detail}, {"instance", p.Instance}}
+	if p.Status != 0 {
+		members = append(members, [2]string{"status", fmt.Sprint(p.Status)})
+	}
+
+	keys := make([]string, 0, len(p.Extensions))
+	for k := range p.Extensions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		members = append(members, [2]string{k, fmt.Sprint(p.Extensions[k])})
+	}
+
+	for _, m := range members {
+		if m[1] == "" {
+			continue
+		}
+		if err := e.EncodeElement(m[1], xml.StartElement{Name: xml.Name{Local: m[0]}}); err != nil {
+			return err
+		}
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+// ValidationError describes why the value of a single field is invalid.
+type ValidationError struct {
+	Field   string `json:"field" xml:"field"`
+	Message string `json:"message" xml:"message"`
+}
+
+// ValidationErrors is a list of ValidationError. When returned from a handler, the default error handler
+// responds with `http.StatusUnprocessableEntity` and lists them in the `errors` extension member.
+type ValidationErrors []ValidationError
+
+func (v ValidationErrors) Error() string {
+	msgs := make([]string, len(v))
+	for i, e := range v {
+		msgs[i] = e.Field + ": " + e.Message
+	}
+
+	return "validation failed: " + strings.Join(msgs, ", ")
+}
+
+// ErrorHandler handles the errors returned by middleware and route handlers, as well as requests that don't match any route.
+type ErrorHandler func(ctx *Ctx, err error)
+
+// DefaultErrorHandler renders err as a ProblemDetails with Ctx.Problem, unless a response has already been sent.
+//
+// A *ProblemDetails is rendered as it is and ValidationErrors are rendered with `http.StatusUnprocessableEntity`.
+// Any other error is rendered as `http.StatusInternalServerError` without its message, so that internals don't leak to the client.
+func DefaultErrorHandler(ctx *Ctx, err error) {
+	if ctx.Response.Committed() {
+		return
+	}
+
+	_ = ctx.Problem(problemFromError(err))
+}
+
+// problemFromError converts err to the ProblemDetails that describes it to the client.
+func problemFromError(err error) *ProblemDetails {
+	var problem *ProblemDetails
+	if errors.As(err, &problem) {
+		return problem
+	}
+
+	var validationErrs ValidationErrors
+	if errors.As(err, &validationErrs) {
+		return NewProblem(http.StatusUnprocessableEntity, "the request contains invalid fields").With("errors", []ValidationError(validationErrs))
+	}
+
+	if errors.Is(err, ErrNotAcceptable) {
+		return NewProblem(http.StatusNotAcceptable, "")
+	}
+
+	return NewProblem(http.StatusInternalServerError, "")
+}
+
+// problemMediaTypes maps media types to their problem details counterparts
+var problemMediaTypes = map[string]string{
+	"application/json": "application/problem+json",
+	"application/xml":  "application/problem+xml",
+}
+
+// Problem sends p to the client with its status code. The representation is picked from the registered encoders
+// based on the Accept header of the request, with JSON and XML being sent as `application/problem+json` and
+// `application/problem+xml`. If the client doesn't accept any of them, `application/problem+json` is sent anyway.
+//
+// If p has no instance, the path of the request is used. p itself is left untouched, so the same problem can be
+// sent by concurrent requests (e.g. a package-level `var ErrQuota = goster.NewProblem(...)`).
+func (c *Ctx) Problem(problem *ProblemDetails) (err error) {
+	p := *problem
+	if p.Status == 0 {
+		p.Status = http.StatusInternalServerError
+	}
+	if p.Instance == "" {
+		p.Instance = c.Request.URL.Path
+	}
+
+	var enc Encoder = JSONEncoder{}
+	contentType := "application/problem+json"
+	if accept := c.Request.Header.Get("Accept"); strings.TrimSpace(accept) != "" {
+		ranges := parseAccept(accept)
+		best := 0.0
+		for _, e := range c.encoders() {
+			mediaType := e.MediaType()
+			q := acceptQuality(ranges, mediaType)
+			if problemType, ok := problemMediaTypes[mediaType]; ok {
+				mediaType = problemType
+				q = max(q, acceptQuality(ranges, problemType))
+			}
+
+			if q > best {
+				enc, contentType, best = e, mediaType, q
+			}
+		}
+	}
+
+	err = c.encode(enc, contentType, p.Status, &p)
+	if err != nil {
+		// the picked encoder doesn't support problems, fall back to JSON
+		err = c.encode(JSONEncoder{}, "application/problem+json", p.Status, &p)
+	}
+
+	return
+}
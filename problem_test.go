@@ -0,0 +1,121 @@
+package goster
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type ProblemCase struct {
+	name                string
+	url                 string
+	accept              string
+	expectedStatus      int
+	expectedContentType string
+	expectedBody        string
+}
+
+func TestDefaultErrorHandler(t *testing.T) {
+	g := NewServer()
+	_ = g.Get("/problem/validation", func(ctx *Ctx) error {
+		return ValidationErrors{{Field: "age", Message: "must be positive"}}
+	})
+	_ = g.Get("/problem/custom", func(ctx *Ctx) error {
+		return NewProblem(http.StatusConflict, "user already exists").With("user", "dimitris")
+	})
+	_ = g.Get("/problem/internal", func(ctx *Ctx) error {
+		return errors.New("database password is hunter2")
+	})
+
+	testCases := []ProblemCase{
+		{"Validation errors", "/problem/validation", "", http.StatusUnprocessableEntity, "application/problem+json", `"errors":[{"field":"age","message":"must be positive"}]`},
+		{"Problem details with extension", "/problem/custom", "application/json", http.StatusConflict, "application/problem+json", `"user":"dimitris"`},
+		{"Problem details as problem+json", "/problem/custom", "application/problem+json", http.StatusConflict, "application/problem+json", `"detail":"user already exists"`},
+		{"Problem details as XML", "/problem/custom", "application/xml", http.StatusConflict, "application/problem+xml", `<problem xmlns="urn:ietf:rfc:7807">`},
+		{"Problem details as text", "/problem/custom", "text/plain", http.StatusConflict, "text/plain; charset=utf-8", "409 Conflict: user already exists"},
+		{"Unacceptable problem falls back to JSON", "/problem/custom", "image/png", http.StatusConflict, "application/problem+json", `"status":409`},
+		{"Internal errors don't leak", "/problem/internal", "", http.StatusInternalServerError, "application/problem+json", `"title":"Internal Server Error"`},
+		{"Unknown route", "/problem/unknown", "", http.StatusNotFound, "application/problem+json", `"instance":"/problem/unknown"`},
+	}
+
+	failedCases := make(map[int]ProblemCase, 0)
+	for i, c := range testCases {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, c.url, nil)
+		if c.accept != "" {
+			req.Header.Set("Accept", c.accept)
+		}
+		g.ServeHTTP(rec, req)
+
+		body := rec.Body.String()
+		if rec.Code != c.expectedStatus || rec.Header().Get("Content-Type") != c.expectedContentType || !strings.Contains(body, c.expectedBody) || strings.Contains(body, "hunter2") {
+			failedCases[i] = c
+			t.Errorf("FAILED [%d] - %s: got %d %q %s", i, c.name, rec.Code, rec.Header().Get("Content-Type"), body)
+		} else {
+			t.Logf("PASSED [%d] - %s\n", i, c.name)
+		}
+	}
+
+	t.Logf("TOTAL CASES: %d\n", len(testCases))
+	t.Logf("FAILED CASES: %d\n", len(failedCases))
+}
+
+func TestProblemDetailsJSON(t *testing.T) {
+	p := NewProblem(http.StatusForbidden, "not yours").With("balance", 30)
+	p.Type = "https://example.com/probs/out-of-credit"
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	members := map[string]any{}
+	if err := json.Unmarshal(b, &members); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]any{
+		"type":    "https://example.com/probs/out-of-credit",
+		"title":   "Forbidden",
+		"status":  float64(http.StatusForbidden),
+		"detail":  "not yours",
+		"balance": float64(30),
+	}
+	for k, v := range expected {
+		if members[k] != v {
+			t.Errorf("expected member `%s` to be %v, got %v", k, v, members[k])
+		}
+	}
+	if _, exists := members["instance"]; exists {
+		t.Errorf("expected empty members to be omitted")
+	}
+}
+
+func TestProblemShared(t *testing.T) {
+	g := NewServer()
+	errQuota := &ProblemDetails{Title: "Quota exceeded"}
+
+	for _, route := range []string{"/problem-shared/a", "/problem-shared/b"} {
+		_ = g.Get(route, func(ctx *Ctx) error {
+			return ctx.Problem(errQuota)
+		})
+
+		rec := httptest.NewRecorder()
+		g.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, route, nil))
+
+		body := map[string]any{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != http.StatusInternalServerError || body["instance"] != route {
+			t.Errorf("expected a 500 with the instance %q, got %d: %v", route, rec.Code, body)
+		}
+	}
+
+	if errQuota.Status != 0 || errQuota.Instance != "" {
+		t.Errorf("expected Problem to leave the shared problem untouched, got %+v", errQuota)
+	}
+}
@@ -0,0 +1,140 @@
+package goster
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"log/slog"
+	"time"
+)
+
+// DefaultRequestIDHeader is the header RequestID reads and sets by default.
+const DefaultRequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the length above which incoming request IDs are replaced, so that clients can't flood the logs
+const maxRequestIDLength = 128
+
+// RequestIDOptions configure the RequestID middleware.
+type RequestIDOptions struct {
+	Header    string        // Header is the header the ID is read from and sent back in, DefaultRequestIDHeader if empty
+	Generator func() string // Generator creates the IDs of requests that don't have one, NewRequestID if nil
+}
+
+// requestIDKey is the key of the request ID in the context.Context of a request
+type requestIDKey struct{}
+
+// RequestID returns a middleware that gives every request an ID: the one in the X-Request-ID header of the request
+// if there's one, or a new one otherwise. The ID is sent back in the same header of the response, can be read with
+// Ctx.RequestID and is added as the "request_id" attribute to everything logged through Ctx.Logger, including
+// the records Goster writes for the request.
+//
+//	g.UseGlobal(goster.RequestID())
+//
+// Incoming IDs that are longer than 128 characters or contain anything but printable ASCII are replaced by a new one.
+// The ID is also stored in the context of the request (see RequestIDFromContext), so that it can be passed on to other services.
+func RequestID(opts ...RequestIDOptions) RequestHandler {
+	var options RequestIDOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	if options.Header == "" {
+		options.Header = DefaultRequestIDHeader
+	}
+	if options.Generator == nil {
+		options.Generator = NewRequestID
+	}
+
+	return func(ctx *Ctx) error {
+		id := ctx.Request.Header.Get(options.Header)
+		if !validRequestID(id) {
+			id = options.Generator()
+		}
+
+		ctx.requestID = id
+		ctx.logger = nil
+		ctx.Response.Header().Set(options.Header, id)
+		ctx.SetContext(context.WithValue(ctx.Context(), requestIDKey{}, id))
+		return nil
+	}
+}
+
+// RequestID returns the ID given to the request by the RequestID middleware, or "" if it doesn't use it.
+func (c *Ctx) RequestID() string {
+	return c.requestID
+}
+
+// RequestIDFromContext returns the request ID stored in ctx by the RequestID middleware, or "" if there's none.
+// Pass it on in the X-Request-ID header of outgoing requests to correlate the logs of your services.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Logger returns the logger of the server with the attributes of the request: its "request_id" when the RequestID
+// middleware is used, and its "trace_id" and "span_id" when the Tracing middleware is used. Log through it in handlers
+// so that records can be traced back to requests.
+func (c *Ctx) Logger() *slog.Logger {
+	if c.logger != nil {
+		return c.logger
+	}
+	if c.g == nil || c.g.Logger == nil {
+		return slog.Default()
+	}
+	if c.requestID == "" && c.span == nil {
+		return c.g.Logger
+	}
+
+	attrs := make([]any, 0, 3)
+	if c.requestID != "" {
+		attrs = append(attrs, slog.String("request_id", c.requestID))
+	}
+	if c.span != nil {
+		attrs = append(attrs, slog.String("trace_id", c.span.sc.TraceID.String()), slog.String("span_id", c.span.sc.SpanID.String()))
+	}
+
+	c.logger = c.g.Logger.With(attrs...)
+	return c.logger
+}
+
+// validRequestID reports whether id is acceptable as the ID of a request
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// NewRequestID returns a new UUID version 7 (RFC 9562), like "01956f3c-2a4b-7c1d-9e2f-3a4b5c6d7e8f". The first 48 bits
+// are the time in milliseconds, so IDs sort by the time they were created, and the rest is random.
+func NewRequestID() string {
+	var u [16]byte
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(time.Now().UnixMilli()))
+	copy(u[:6], ms[2:])
+
+	if _, err := rand.Read(u[6:]); err != nil {
+		panic("goster: could not generate a request ID: " + err.Error())
+	}
+	u[6] = u[6]&0x0f | 0x70 // version 7
+	u[8] = u[8]&0x3f | 0x80 // variant 10
+
+	var b [36]byte
+	hex.Encode(b[0:8], u[0:4])
+	b[8] = '-'
+	hex.Encode(b[9:13], u[4:6])
+	b[13] = '-'
+	hex.Encode(b[14:18], u[6:8])
+	b[18] = '-'
+	hex.Encode(b[19:23], u[8:10])
+	b[23] = '-'
+	hex.Encode(b[24:], u[10:])
+
+	return string(b[:])
+}
@@ -0,0 +1,124 @@
+package goster
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var uuidV7Pattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+type RequestIDCase struct {
+	name       string
+	opts       RequestIDOptions
+	header     string
+	incoming   string
+	generated  bool   // generated reports whether a new ID is expected instead of the incoming one
+	expectedID string // expectedID is the expected ID when it's not generated by NewRequestID
+}
+
+func TestRequestID(t *testing.T) {
+	g := NewServer()
+
+	testCases := []RequestIDCase{
+		{"Generated", RequestIDOptions{}, "X-Request-ID", "", true, ""},
+		{"Incoming", RequestIDOptions{}, "X-Request-ID", "abc-123", false, "abc-123"},
+		{"Invalid incoming", RequestIDOptions{}, "X-Request-ID", "bad id\n", true, ""},
+		{"Too long incoming", RequestIDOptions{}, "X-Request-ID", strings.Repeat("a", 129), true, ""},
+		{"Custom header", RequestIDOptions{Header: "X-Correlation-ID"}, "X-Correlation-ID", "corr-1", false, "corr-1"},
+		{"Custom generator", RequestIDOptions{Generator: func() string { return "fixed" }}, "X-Request-ID", "", false, "fixed"},
+	}
+
+	failedCases := make(map[int]RequestIDCase, 0)
+	for i, c := range testCases {
+		route := "/request-id/" + strings.ReplaceAll(strings.ToLower(c.name), " ", "-")
+		var seen, fromContext string
+		_ = g.Get(route, func(ctx *Ctx) error {
+			seen = ctx.RequestID()
+			fromContext = RequestIDFromContext(ctx.Context())
+			return nil
+		})
+		g.Use(route, RequestID(c.opts))
+
+		req := httptest.NewRequest(http.MethodGet, route, nil)
+		if c.incoming != "" {
+			req.Header.Set(c.header, c.incoming)
+		}
+		rec := httptest.NewRecorder()
+		g.ServeHTTP(rec, req)
+
+		sent := rec.Header().Get(c.header)
+		failed := sent == "" || sent != seen || sent != fromContext
+		if c.generated {
+			failed = failed || !uuidV7Pattern.MatchString(sent)
+		} else {
+			failed = failed || sent != c.expectedID
+		}
+
+		if failed {
+			failedCases[i] = c
+			t.Errorf("FAILED [%d] - %s: sent %q, handler saw %q and %q", i, c.name, sent, seen, fromContext)
+		} else {
+			t.Logf("PASSED [%d] - %s\n", i, c.name)
+		}
+	}
+
+	t.Logf("TOTAL CASES: %d\n", len(testCases))
+	t.Logf("FAILED CASES: %d\n", len(failedCases))
+}
+
+func TestRequestIDLogs(t *testing.T) {
+	g := NewServer()
+	logger := g.Logger
+	defer func() { g.Logger = logger }()
+
+	var buf bytes.Buffer
+	g.Logger = NewLogger(&buf, LogFormatJSON, slog.LevelInfo)
+
+	_ = g.Get("/request-id-logs", func(ctx *Ctx) error {
+		ctx.Logger().Info("handling")
+		return errors.New("boom")
+	})
+	g.Use("/request-id-logs", RequestID())
+
+	req := httptest.NewRequest(http.MethodGet, "/request-id-logs", nil)
+	req.Header.Set("X-Request-ID", "trace-me")
+	g.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected the handler, error and request records, got %q", buf.String())
+	}
+	for _, line := range lines {
+		rec := map[string]any{}
+		if err := json.Unmarshal([]byte(line), &rec); err != nil || rec["request_id"] != "trace-me" {
+			t.Errorf("expected the record to carry the request ID, got %q", line)
+		}
+	}
+}
+
+func TestNewRequestID(t *testing.T) {
+	seen := make(map[string]bool)
+	prev := ""
+	for i := 0; i < 1000; i++ {
+		id := NewRequestID()
+		if !uuidV7Pattern.MatchString(id) {
+			t.Fatalf("expected a UUIDv7, got %q", id)
+		}
+		if seen[id] {
+			t.Fatalf("duplicate ID %q", id)
+		}
+		// the timestamp comes first, so IDs of later milliseconds sort after earlier ones
+		if id[:13] < prev {
+			t.Fatalf("expected %q to sort after %q", id, prev)
+		}
+		seen[id] = true
+		prev = id[:13]
+	}
+}
@@ -1,11 +1,28 @@
 package goster
 
 import (
+	"bufio"
+	"io"
+	"net"
 	"net/http"
+	"time"
 )
 
+// Response wraps the http.ResponseWriter of a request and keeps track of what has been sent to the client:
+// the status code, the number of body bytes written and the time at which the first byte went out.
+//
+// The underlying writer can be reached through Unwrap, which makes Response compatible with http.ResponseController.
 type Response struct {
 	http.ResponseWriter
+	status    int       // status is the status code sent to the client (0 if nothing has been sent yet)
+	written   int64     // written is the number of body bytes written so far
+	start     time.Time // start is the time the response was created
+	firstByte time.Time // firstByte is the time the header got flushed to the writer
+}
+
+// newResponse creates a Response wrapping w and starts its timer.
+func newResponse(w http.ResponseWriter) Response {
+	return Response{ResponseWriter: w, start: time.Now()}
 }
 
 // Supply h with a map[string]string for the headers and s with an int representing the response status code or use the http.Status(...). They keys and values will be translated to the header of the response and the header will be locked afterwards not allowing changes to be made.
@@ -16,3 +33,118 @@ func (r *Response) NewHeaders(h map[string]string, s int) {
 
 	r.WriteHeader(s)
 }
+
+// WriteHeader sends the status code s to the client and records it. Only the first call has any effect,
+// subsequent calls are ignored just like they are by net/http.
+func (r *Response) WriteHeader(s int) {
+	if r.status != 0 {
+		return
+	}
+
+	r.status = s
+	r.firstByte = time.Now()
+	r.ResponseWriter.WriteHeader(s)
+}
+
+// Write writes b to the client, sending a 200 status first if no status has been sent yet.
+func (r *Response) Write(b []byte) (n int, err error) {
+	if r.status == 0 {
+		r.WriteHeader(http.StatusOK)
+	}
+
+	n, err = r.ResponseWriter.Write(b)
+	r.written += int64(n)
+	return
+}
+
+// WriteString writes s to the client without converting it to a []byte when the underlying writer supports it.
+func (r *Response) WriteString(s string) (n int, err error) {
+	if r.status == 0 {
+		r.WriteHeader(http.StatusOK)
+	}
+
+	n, err = io.WriteString(r.ResponseWriter, s)
+	r.written += int64(n)
+	return
+}
+
+// ReadFrom copies src to the client. If the underlying writer implements io.ReaderFrom (like the one net/http uses)
+// it is used directly so that optimizations like sendfile still apply.
+func (r *Response) ReadFrom(src io.Reader) (n int64, err error) {
+	if r.status == 0 {
+		r.WriteHeader(http.StatusOK)
+	}
+
+	if rf, ok := r.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(src)
+	} else {
+		n, err = io.Copy(r.ResponseWriter, src)
+	}
+	r.written += n
+	return
+}
+
+// Status returns the status code sent to the client. If nothing has been sent yet it returns 0.
+func (r *Response) Status() int {
+	return r.status
+}
+
+// Written returns the number of body bytes that have been written to the client.
+func (r *Response) Written() int64 {
+	return r.written
+}
+
+// Committed reports whether the status code and headers have already been sent to the client,
+// in which case changing them has no effect anymore.
+func (r *Response) Committed() bool {
+	return r.status != 0
+}
+
+// Elapsed returns the time that has passed since the response was created.
+func (r *Response) Elapsed() time.Duration {
+	return time.Since(r.start)
+}
+
+// TimeToFirstByte returns the time it took from the creation of the response until the header was sent.
+// If the header hasn't been sent yet it returns 0.
+func (r *Response) TimeToFirstByte() time.Duration {
+	if r.firstByte.IsZero() {
+		return 0
+	}
+
+	return r.firstByte.Sub(r.start)
+}
+
+// Flush sends any buffered data to the client. It's a no-op if the underlying writer doesn't support flushing.
+func (r *Response) Flush() {
+	if r.status == 0 {
+		r.WriteHeader(http.StatusOK)
+	}
+
+	_ = http.NewResponseController(r.ResponseWriter).Flush()
+}
+
+// Hijack lets the caller take over the connection. See http.Hijacker for details.
+func (r *Response) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(r.ResponseWriter).Hijack()
+}
+
+// Push initiates an HTTP/2 server push. If the underlying writer doesn't support pushing http.ErrNotSupported is returned.
+func (r *Response) Push(target string, opts *http.PushOptions) error {
+	w := r.ResponseWriter
+	for {
+		switch t := w.(type) {
+		case http.Pusher:
+			return t.Push(target, opts)
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = t.Unwrap()
+		default:
+			return http.ErrNotSupported
+		}
+	}
+}
+
+// Unwrap returns the underlying http.ResponseWriter. It's used by http.ResponseController to reach the original writer.
+func (r *Response) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
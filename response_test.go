@@ -0,0 +1,74 @@
+package goster
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResponseTracking(t *testing.T) {
+	rec := httptest.NewRecorder()
+	res := newResponse(rec)
+
+	if res.Committed() || res.Status() != 0 || res.TimeToFirstByte() != 0 {
+		t.Fatalf("expected a fresh response, got status %d", res.Status())
+	}
+
+	_, _ = res.Write([]byte("hello"))
+	_, _ = res.WriteString(", world")
+	_, _ = res.ReadFrom(strings.NewReader("!"))
+	res.WriteHeader(http.StatusTeapot) // should be ignored
+
+	if res.Status() != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, res.Status())
+	}
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected recorded status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if res.Written() != int64(len("hello, world!")) {
+		t.Errorf("expected %d bytes written, got %d", len("hello, world!"), res.Written())
+	}
+	if rec.Body.String() != "hello, world!" {
+		t.Errorf("unexpected body %q", rec.Body.String())
+	}
+	if !res.Committed() || res.Elapsed() < res.TimeToFirstByte() {
+		t.Errorf("expected response to be committed with timing information")
+	}
+}
+
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (h *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h.hijacked = true
+	return nil, nil, nil
+}
+
+func TestResponseController(t *testing.T) {
+	rec := &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+	res := newResponse(rec)
+
+	rc := http.NewResponseController(&res)
+	if err := rc.Flush(); err != nil {
+		t.Errorf("flush through controller failed: %s", err)
+	}
+	if !rec.Flushed {
+		t.Errorf("expected underlying recorder to be flushed")
+	}
+
+	if _, _, err := rc.Hijack(); err != nil {
+		t.Errorf("hijack through controller failed: %s", err)
+	}
+	if !rec.hijacked {
+		t.Errorf("expected underlying writer to be hijacked")
+	}
+
+	if err := res.Push("/style.css", nil); err != http.ErrNotSupported {
+		t.Errorf("expected %v, got %v", http.ErrNotSupported, err)
+	}
+}
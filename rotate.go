@@ -0,0 +1,347 @@
+package goster
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// backupTimeLayout is the layout of the time in the names of rotated files. It sorts chronologically and has no characters
+// that are invalid in file names.
+const backupTimeLayout = "2006-01-02T15-04-05.000"
+
+// RotateOptions configure when a RotatingFile is rotated and what happens to the rotated files.
+type RotateOptions struct {
+	MaxSize    int64         // MaxSize is the size in bytes the file may reach before it's rotated. 0 means no limit
+	Interval   time.Duration // Interval rotates the file at every multiple of it (e.g. 24 * time.Hour rotates daily at midnight UTC). 0 disables it
+	MaxBackups int           // MaxBackups is the number of rotated files that are kept, the oldest ones are deleted. 0 keeps them all
+	Compress   bool          // Compress compresses rotated files with gzip
+}
+
+// RotatingFile is an io.Writer that appends to a file and rotates it once it gets too big or too old. The rotated file
+// is renamed with the time of the rotation, so "app.log" becomes "app-2025-03-07T00-00-00.000.log" (".gz" if it's compressed),
+// and writing continues in a new "app.log". It's safe for concurrent use.
+//
+// Use it as the output of the logger of a server (see Goster.SetLogOutput) or of the AccessLog middleware.
+type RotatingFile struct {
+	path string
+	opts RotateOptions
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+	next time.Time // next is when the file is rotated because of Interval
+
+	mill   sync.WaitGroup // mill tracks the goroutines compressing and deleting rotated files
+	millMu sync.Mutex     // millMu makes sure that only one of them runs at a time
+	now    func() time.Time
+}
+
+// NewRotatingFile opens (or creates) the file at path for appending, rotating it as opts describe.
+// The directory of the file must exist.
+//
+//	f, err := goster.NewRotatingFile("/var/log/app/app.log", goster.RotateOptions{MaxSize: 100 << 20, MaxBackups: 7, Compress: true})
+func NewRotatingFile(path string, opts ...RotateOptions) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, now: time.Now}
+	if len(opts) > 0 {
+		f.opts = opts[0]
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Write writes p to the file, rotating it first if p would make it exceed RotateOptions.MaxSize or if RotateOptions.Interval
+// has passed since the last rotation.
+func (f *RotatingFile) Write(p []byte) (n int, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	tooBig := f.opts.MaxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.opts.MaxSize
+	tooOld := !f.next.IsZero() && !f.now().Before(f.next)
+	if tooBig || tooOld {
+		if err = f.rotate(); err != nil {
+			return
+		}
+	}
+
+	n, err = f.file.Write(p)
+	f.size += int64(n)
+	return
+}
+
+// Rotate rotates the file right away.
+func (f *RotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	return f.rotate()
+}
+
+// Reopen closes the file and opens the file at the same path again, creating it if it's gone. It's meant for external
+// tools like logrotate, that move the file away and then signal the process to let go of it.
+func (f *RotatingFile) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	return f.open()
+}
+
+// ReopenOnSignal reopens the file (see Reopen) every time the process receives one of sigs, SIGHUP if none are given,
+// which is what logrotate sends by default. The returned function stops listening for the signals.
+//
+// Errors while reopening are written to stderr, since the log file is the one that's failing.
+func (f *RotatingFile) ReopenOnSignal(sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{syscall.SIGHUP}
+	}
+
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sigs...)
+
+	go func() {
+		for {
+			select {
+			case <-ch:
+				if err := f.Reopen(); err != nil {
+					fmt.Fprintf(os.Stderr, "goster: could not reopen log file `%s`: %s\n", f.path, err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
+
+// Close closes the file, waiting for rotated files to be compressed and deleted.
+func (f *RotatingFile) Close() (err error) {
+	f.mu.Lock()
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+	}
+	f.mu.Unlock()
+
+	f.mill.Wait()
+	return
+}
+
+// open opens the file at f.path for appending and works out when it has to be rotated next. f.mu must be held.
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.file = file
+	f.size = info.Size()
+	if f.opts.Interval > 0 {
+		f.next = f.now().Truncate(f.opts.Interval).Add(f.opts.Interval)
+	}
+	return nil
+}
+
+// rotate renames the file after the current time and opens a new one in its place. The rotated files are then
+// compressed and deleted in the background. f.mu must be held.
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(f.path, f.backupName(f.now())); err != nil && !os.IsNotExist(err) {
+		// keep writing to the current file rather than losing logs
+		if openErr := f.open(); openErr != nil {
+			f.file = nil
+			return openErr
+		}
+		return err
+	}
+
+	if err := f.open(); err != nil {
+		f.file = nil
+		return err
+	}
+
+	if f.opts.Compress || f.opts.MaxBackups > 0 {
+		f.mill.Add(1)
+		go func() {
+			defer f.mill.Done()
+			f.millBackups()
+		}()
+	}
+	return nil
+}
+
+// backupName returns the name the file gets when it's rotated at t
+func (f *RotatingFile) backupName(t time.Time) string {
+	ext := filepath.Ext(f.path)
+	return strings.TrimSuffix(f.path, ext) + "-" + t.UTC().Format(backupTimeLayout) + ext
+}
+
+// millBackups compresses the rotated files if RotateOptions.Compress is set and deletes the oldest ones beyond RotateOptions.MaxBackups.
+// Errors are written to stderr, since the logs are what's failing.
+func (f *RotatingFile) millBackups() {
+	f.millMu.Lock()
+	defer f.millMu.Unlock()
+
+	backups, err := f.backups()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "goster: could not list rotated log files: %s\n", err)
+		return
+	}
+
+	if f.opts.MaxBackups > 0 && len(backups) > f.opts.MaxBackups {
+		for _, b := range backups[f.opts.MaxBackups:] {
+			if err := os.Remove(b); err != nil {
+				fmt.Fprintf(os.Stderr, "goster: could not delete rotated log file: %s\n", err)
+			}
+		}
+		backups = backups[:f.opts.MaxBackups]
+	}
+
+	if !f.opts.Compress {
+		return
+	}
+	for _, b := range backups {
+		if strings.HasSuffix(b, ".gz") {
+			continue
+		}
+		if err := gzipFile(b); err != nil {
+			fmt.Fprintf(os.Stderr, "goster: could not compress rotated log file: %s\n", err)
+		}
+	}
+}
+
+// backups returns the paths of the rotated files of f, from the newest to the oldest
+func (f *RotatingFile) backups() ([]string, error) {
+	dir := filepath.Dir(f.path)
+	ext := filepath.Ext(f.path)
+	prefix := strings.TrimSuffix(filepath.Base(f.path), ext) + "-"
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	type backup struct {
+		path string
+		t    time.Time
+	}
+	found := []backup{}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		stamp := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".gz"), ext)
+		t, err := time.Parse(backupTimeLayout, stamp)
+		if err != nil {
+			continue
+		}
+		found = append(found, backup{filepath.Join(dir, name), t})
+	}
+
+	sort.Slice(found, func(i, j int) bool { return found[i].t.After(found[j].t) })
+
+	paths := make([]string, len(found))
+	for i, b := range found {
+		paths[i] = b.path
+	}
+	return paths, nil
+}
+
+// gzipFile compresses the file at p into p.gz and deletes it
+func gzipFile(p string) (err error) {
+	src, err := os.Open(p)
+	if err != nil {
+		return
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(p+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return
+	}
+
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(p + ".gz")
+		return
+	}
+
+	src.Close()
+	return os.Remove(p)
+}
+
+// SetLogOutput replaces the logger of the server with one that writes to w in the given format, like a RotatingFile:
+//
+//	f, err := goster.NewRotatingFile("app.log", goster.RotateOptions{MaxSize: 50 << 20, MaxBackups: 5})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer f.Close()
+//	f.ReopenOnSignal()
+//	g.SetLogOutput(f, goster.LogFormatJSON)
+//
+// The new logger still uses LogLevel as its minimum level and still fills Logs.
+func (g *Goster) SetLogOutput(w io.Writer, format LogFormat) {
+	var level slog.Leveler = slog.LevelInfo
+	if g.LogLevel != nil {
+		level = g.LogLevel
+	}
+
+	g.Logger = NewLogger(w, format, level)
+	if g.Logs != nil {
+		g.Logger = slog.New(g.Logs.Handler(g.Logger.Handler()))
+	}
+}
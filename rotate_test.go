@@ -0,0 +1,199 @@
+package goster
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClock returns a clock for RotatingFile.now that starts at start and moves forward by a millisecond on every call
+func fakeClock(start time.Time) func() time.Time {
+	now := start
+	return func() time.Time {
+		now = now.Add(time.Millisecond)
+		return now
+	}
+}
+
+// readLogDir returns the contents of the files in dir by name, decompressing gzipped ones
+func readLogDir(t *testing.T, dir string) map[string]string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := make(map[string]string)
+	for _, e := range entries {
+		f, err := os.Open(filepath.Join(dir, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var r io.Reader = f
+		if strings.HasSuffix(e.Name(), ".gz") {
+			if r, err = gzip.NewReader(f); err != nil {
+				t.Fatal(err)
+			}
+		}
+		b, _ := io.ReadAll(r)
+		f.Close()
+		files[e.Name()] = string(b)
+	}
+	return files
+}
+
+// sortedContents returns the contents of files sorted by file name, which for rotated files is chronological
+func sortedContents(files map[string]string, skip string) []string {
+	names := []string{}
+	for name := range files {
+		if name != skip {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	contents := make([]string, len(names))
+	for i, name := range names {
+		contents[i] = files[name]
+	}
+	return contents
+}
+
+func TestRotatingFileSize(t *testing.T) {
+	dir := t.TempDir()
+	f, err := NewRotatingFile(filepath.Join(dir, "app.log"), RotateOptions{MaxSize: 10, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.now = fakeClock(time.Date(2025, 3, 7, 0, 0, 0, 0, time.UTC))
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files := readLogDir(t, dir)
+	if files["app.log"] != "fourth\n" {
+		t.Errorf("expected the current file to hold the last line, got %q", files["app.log"])
+	}
+	if backups := sortedContents(files, "app.log"); strings.Join(backups, "") != "second\nthird\n" {
+		t.Errorf("expected the 2 newest backups to be kept, got %v", files)
+	}
+	for name := range files {
+		if name != "app.log" && (!strings.HasPrefix(name, "app-2025-03-07T") || !strings.HasSuffix(name, ".log")) {
+			t.Errorf("unexpected file name %q", name)
+		}
+	}
+}
+
+func TestRotatingFileInterval(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2025, 3, 7, 23, 0, 0, 0, time.UTC)
+	f, err := NewRotatingFile(filepath.Join(dir, "app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.now = func() time.Time { return now }
+	f.opts.Interval = 24 * time.Hour
+	f.Reopen() // picks up the fake clock and the interval
+
+	f.Write([]byte("day one\n"))
+	now = now.Add(30 * time.Minute)
+	f.Write([]byte("still day one\n"))
+	now = now.Add(time.Hour)
+	f.Write([]byte("day two\n"))
+	f.Close()
+
+	files := readLogDir(t, dir)
+	if len(files) != 2 || files["app.log"] != "day two\n" || files["app-2025-03-08T00-30-00.000.log"] != "day one\nstill day one\n" {
+		t.Errorf("expected a rotation at midnight, got %v", files)
+	}
+}
+
+func TestRotatingFileCompress(t *testing.T) {
+	dir := t.TempDir()
+	f, err := NewRotatingFile(filepath.Join(dir, "app.log"), RotateOptions{Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.now = fakeClock(time.Date(2025, 3, 7, 0, 0, 0, 0, time.UTC))
+
+	f.Write([]byte("old\n"))
+	if err := f.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("new\n"))
+	f.Close()
+
+	files := readLogDir(t, dir)
+	if len(files) != 2 || files["app.log"] != "new\n" || files["app-2025-03-07T00-00-00.001.log.gz"] != "old\n" {
+		t.Errorf("expected the rotated file to be gzipped, got %v", files)
+	}
+}
+
+func TestRotatingFileReopen(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "app.log")
+	f, err := NewRotatingFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	f.Write([]byte("before\n"))
+	// what logrotate does before sending SIGHUP
+	if err := os.Rename(p, p+".1"); err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("moved\n"))
+	if err := f.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("after\n"))
+
+	files := readLogDir(t, dir)
+	if files["app.log.1"] != "before\nmoved\n" || files["app.log"] != "after\n" {
+		t.Errorf("expected writes to continue in a new file, got %v", files)
+	}
+}
+
+func TestSetLogOutput(t *testing.T) {
+	g := NewServer()
+	logger := g.Logger
+	defer func() { g.Logger = logger }()
+
+	dir := t.TempDir()
+	f, err := NewRotatingFile(filepath.Join(dir, "server.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.SetLogOutput(f, LogFormatJSON)
+
+	since := time.Now()
+	g.Logger.Debug("set-log-output debug")
+	g.Logger.Info("set-log-output info", "path", "/set-log-output")
+	f.Close()
+
+	rec := map[string]any{}
+	if err := json.Unmarshal([]byte(readLogDir(t, dir)["server.log"]), &rec); err != nil || rec["msg"] != "set-log-output info" {
+		t.Errorf("expected a single JSON record in the file, got %v (%v)", rec, err)
+	}
+	if entries := g.RecentLogs(LogFilter{Since: since, Path: "/set-log-output"}); len(entries) != 1 {
+		t.Errorf("expected the record to be kept in the log buffer too, got %v", entries)
+	}
+	if g.Logger.Enabled(context.Background(), slog.LevelDebug) {
+		t.Errorf("expected the new logger to use the level of the server")
+	}
+}
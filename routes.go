@@ -2,36 +2,30 @@ package goster
 
 import (
 	"fmt"
-	"io"
-	"os"
-	"path/filepath"
+	"io/fs"
+	"path"
 	"strings"
 )
 
 type Routes map[string]map[string]Route
 
-func (rs *Routes) prepareStaticRoutes(dir string) (err error) {
-	staticPaths := engine.Config.StaticFilePaths
-	for relPath := range staticPaths {
-		staticPath := staticPaths[relPath]
-		file, err := os.Open(staticPath)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "cannot open static file `%s`\n", file.Name())
-			return err
-		}
-
-		// register a GET route that serves the static file
-		routePath := filepath.Join(dir, relPath)
-		cleanPath(&routePath)
-		err = rs.New("GET", routePath, func(ctx *Ctx) error {
-			return staticFileHandler(ctx, file)
-		})
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "couldn't add route `%s`. Most likely there's a duplicate entry\n", routePath)
+// prepareStaticRoutes registers a single GET route under prefix that serves the files of fsys according to opts. The files
+// are looked up when they're requested, so files added to fsys after the route is registered are served as well.
+func (rs *Routes) prepareStaticRoutes(prefix string, fsys fs.FS, opts StaticOptions) (err error) {
+	sh := newStaticHandler(prefix, fsys, opts)
+	if opts.Fingerprint {
+		if err := sh.fingerprint(); err != nil {
+			return fmt.Errorf("could not fingerprint static files: %w", err)
 		}
+		engine.staticMounts = append(engine.staticMounts, sh)
 	}
 
-	return
+	routePath := path.Join(prefix, "*filepath")
+	cleanPath(&routePath)
+	return rs.New("GET", routePath, func(ctx *Ctx) error {
+		name, _ := ctx.Path.Get("filepath")
+		return sh.serve(ctx, name)
+	})
 }
 
 // New creates a new Route for the specified method and url using the provided handler. If the Route already exists an error is returned.
@@ -44,7 +38,9 @@ func (rs *Routes) New(method string, url string, handler RequestHandler) (err er
 	}
 
 	routeType := "normal"
-	if strings.Contains(url, ":") {
+	if strings.Contains(url, "/*") {
+		routeType = "wildcard"
+	} else if strings.Contains(url, ":") {
 		routeType = "dynamic"
 	}
 
@@ -79,21 +75,3 @@ func (g *Goster) Put(path string, handler RequestHandler) error {
 func (g *Goster) Delete(path string, handler RequestHandler) error {
 	return g.Routes.New("DELETE", path, handler)
 }
-
-func staticFileHandler(ctx *Ctx, file *os.File) (err error) {
-	// read the file contents
-	_, err = file.Seek(0, 0)
-	if err != nil {
-		return
-	}
-	fInfo, _ := file.Stat()
-	fSize := fInfo.Size()
-	buffer := make([]byte, fSize)
-	_, _ = io.ReadFull(file, buffer)
-
-	// prepare and write response
-	contentType := getContentType(file.Name())
-	ctx.Response.Header().Set("Content-Type", contentType)
-	_, err = ctx.Response.Write(buffer)
-	return
-}
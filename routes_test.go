@@ -2,7 +2,10 @@ package goster
 
 import (
 	"errors"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"testing/fstest"
 )
 
 type MethodNewCase struct {
@@ -61,43 +64,99 @@ func TestMethodNew(t *testing.T) {
 	t.Logf("FAILED CASES: %d\n", len(failedCases))
 }
 
-// TestAddStaticDir verifies that AddStaticDir properly walks the given directory,
-// registers a route for each file (here we create one test file), and that the route's key
-// matches the file's path (normalized to use forward slashes).
+// TestAddStaticDir verifies that prepareStaticRoutes registers a single wildcard route under the given prefix
+// instead of one route per file.
 func TestAddStaticDir(t *testing.T) {
-	// Get the directory of the current executable.
-	// exPath, err := os.Executable()
-	// if err != nil {
-	// 	t.Fatalf("os.Executable() error: %v", err)
-	// }
-
-	testDir := "../static/"
-	// baseDir := filepath.Dir(exPath)
+	fsys := fstest.MapFS{
+		"app.css":      {Data: []byte("body{}")},
+		"js/app.js":    {Data: []byte("alert(1)")},
+		"img/logo.png": {Data: []byte("png")},
+	}
 
 	// Initialize Routes. Ensure that the "GET" method map is created.
 	r := Routes{
 		"GET": make(map[string]Route),
 	}
 
-	// Call AddStaticDir with the relative directory.
-	if err := r.prepareStaticRoutes(testDir); err != nil {
-		t.Fatalf("AddStaticDir returned error: %v", err)
+	if err := r.prepareStaticRoutes("/assets/", fsys, StaticOptions{}); err != nil {
+		t.Fatalf("prepareStaticRoutes returned error: %v", err)
+	}
+
+	expectedKey := "/assets/*filepath"
+	if route, exists := r["GET"][expectedKey]; !exists || route.Handler == nil || route.Type != "wildcard" {
+		t.Errorf("expected wildcard route %q not found in GET routes", expectedKey)
+	} else {
+		t.Logf("PASSED - Route for %q added successfully", expectedKey)
+	}
+	if len(r["GET"]) != 1 {
+		t.Errorf("expected a single route, got %d", len(r["GET"]))
 	}
 
-	for route := range r["GET"] {
-		t.Logf("ROute: %s\n", route)
+	if err := r.prepareStaticRoutes("/assets", fsys, StaticOptions{}); err == nil {
+		t.Errorf("expected an error when registering the same prefix twice")
 	}
+}
 
-	// // Check that the route for the file was added.
-	// if route, exists := r["GET"][expectedKey]; !exists {
-	// 	t.Errorf("expected route %q not found in GET routes", expectedKey)
-	// } else {
-	// 	if route.Handler == nil {
-	// 		t.Errorf("route handler for %q is nil", expectedKey)
-	// 	} else {
-	// 		t.Logf("PASSED - Route for %q added successfully", expectedKey)
-	// 	}
-	// }
-
-	t.Logf("TOTAL ROUTES in GET: %d", len(r["GET"]))
+type MatchRouteCase struct {
+	name           string
+	method         string
+	url            string
+	expectedStatus int
+	expectedBody   string
+}
+
+func TestMatchRoute(t *testing.T) {
+	g := NewServer()
+	_ = g.Get("/match/users", func(ctx *Ctx) error {
+		ctx.Text("users")
+		return nil
+	})
+	_ = g.Get("/match/users/:id", func(ctx *Ctx) error {
+		id, _ := ctx.Path.Get("id")
+		ctx.Text("user " + id)
+		return nil
+	})
+	_ = g.Get("/match/users/:id/books/:book", func(ctx *Ctx) error {
+		id, _ := ctx.Path.Get("id")
+		book, _ := ctx.Path.Get("book")
+		ctx.Text("user " + id + " book " + book)
+		return nil
+	})
+	_ = g.Get("/match/files/*filepath", func(ctx *Ctx) error {
+		file, _ := ctx.Path.Get("filepath")
+		ctx.Text("-" + file)
+		return nil
+	})
+	_ = g.Get("/match/files/nested/*name", func(ctx *Ctx) error {
+		name, _ := ctx.Path.Get("name")
+		ctx.Text("nested " + name)
+		return nil
+	})
+
+	testCases := []MatchRouteCase{
+		{"Static route", "GET", "/match/users", http.StatusOK, "users"},
+		{"Wildcard route", "GET", "/match/files/a/b.txt", http.StatusOK, "-a/b.txt"},
+		{"Wildcard route without rest", "GET", "/match/files", http.StatusOK, "-"},
+		{"Wildcard route with longer prefix", "GET", "/match/files/nested/c.txt", http.StatusOK, "nested c.txt"},
+		{"Dynamic route", "GET", "/match/users/42", http.StatusOK, "user 42"},
+		{"Dynamic route with query", "GET", "/match/users/42?age=24", http.StatusOK, "user 42"},
+		{"Dynamic route with two segments", "GET", "/match/users/42/books/7", http.StatusOK, "user 42 book 7"},
+		{"Wrong method", "POST", "/match/users/42", http.StatusMethodNotAllowed, ""},
+		{"Unknown route", "GET", "/match/users/42/books", http.StatusNotFound, ""},
+	}
+
+	failedCases := make(map[int]MatchRouteCase, 0)
+	for i, c := range testCases {
+		rec := httptest.NewRecorder()
+		g.ServeHTTP(rec, httptest.NewRequest(c.method, c.url, nil))
+		if rec.Code != c.expectedStatus || (c.expectedBody != "" && rec.Body.String() != c.expectedBody) {
+			failedCases[i] = c
+			t.Errorf("FAILED [%d] - %s: got %d %q", i, c.name, rec.Code, rec.Body.String())
+		} else {
+			t.Logf("PASSED [%d] - %s\n", i, c.name)
+		}
+	}
+
+	t.Logf("TOTAL CASES: %d\n", len(testCases))
+	t.Logf("FAILED CASES: %d\n", len(failedCases))
 }
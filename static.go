@@ -0,0 +1,332 @@
+package goster
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CacheRule sets the Cache-Control header of the static files that match Pattern. Patterns use the syntax of path.Match.
+// A pattern without a '/' is matched against the name of the file (e.g. "*.css"), otherwise against its path relative
+// to the static directory (e.g. "img/*.png").
+type CacheRule struct {
+	Pattern string // Pattern selects the files the rule applies to
+	Value   string // Value is the value of the Cache-Control header
+}
+
+// CacheControl makes static files that match pattern be served with the Cache-Control header set to value:
+//
+//	g.CacheControl("*.css", "public, max-age=86400")
+//	g.CacheControl("videos/*", "no-store")
+//
+// Rules are checked in the order they were added and the first one that matches is used. Files that don't match
+// any rule are served without a Cache-Control header. An error is returned if pattern is malformed.
+func (g *Goster) CacheControl(pattern string, value string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid cache pattern `%s`: %w", pattern, err)
+	}
+
+	engine.Config.StaticCacheRules = append(engine.Config.StaticCacheRules, CacheRule{Pattern: pattern, Value: value})
+	return nil
+}
+
+// cacheControl returns the Cache-Control header value of the static file `name`, which is empty if no rule matches.
+func (c *Config) cacheControl(name string) string {
+	for _, rule := range c.StaticCacheRules {
+		target := name
+		if !strings.Contains(rule.Pattern, "/") {
+			target = path.Base(name)
+		}
+
+		if matched, _ := path.Match(strings.TrimPrefix(rule.Pattern, "/"), target); matched {
+			return rule.Value
+		}
+	}
+
+	return ""
+}
+
+// StaticOptions configure how static files are served. They can be given to Goster.StaticDir and Goster.StaticFS.
+type StaticOptions struct {
+	Index    string // Index is the file served for requests to a directory, "index.html" if empty
+	Browse   bool   // Browse lists the contents of directories that don't have an index file
+	Fallback string // Fallback is the file served for paths that don't exist, like the index.html of a single-page app. It's disabled if empty
+
+	// Fingerprint serves every file under a name that contains a hash of its content too (e.g. app.3f9a1c2b.css for app.css),
+	// with a Cache-Control header that lets clients cache it forever. The files are hashed when they're registered, so files
+	// added later aren't fingerprinted. Files that change are hashed again, and their old fingerprinted names stop being served.
+	// Use Goster.Asset or the `asset` template helper to get the fingerprinted URLs.
+	Fingerprint bool
+}
+
+// precompressedEncodings are the content codings of the precompressed files that are served, along with their extensions, in order of preference
+var precompressedEncodings = []struct{ coding, ext string }{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// staticHandler serves the files of fsys under prefix.
+type staticHandler struct {
+	prefix         string
+	fsys           fs.FS
+	opts           StaticOptions
+	fingerprintsMu sync.RWMutex
+	fingerprints   map[string]*fingerprintedFile // fingerprints holds the fingerprinted files keyed by their actual names
+	fingerprinted  map[string]*fingerprintedFile // fingerprinted holds the fingerprinted files keyed by their fingerprinted names
+	etags          sync.Map                      // etags holds the hash based ETags of the files without a modification time, which never change (like the ones of an embed.FS)
+}
+
+func newStaticHandler(prefix string, fsys fs.FS, opts StaticOptions) *staticHandler {
+	if opts.Index == "" {
+		opts.Index = "index.html"
+	}
+
+	return &staticHandler{prefix: path.Join("/", prefix), fsys: fsys, opts: opts}
+}
+
+// serve sends the file at the requested path p of the file system to the client. For a directory its index file or,
+// if browsing is enabled, a listing of its contents is sent instead. If p doesn't exist the fallback file is sent, if any.
+func (sh *staticHandler) serve(ctx *Ctx, p string) (err error) {
+	name, valid := staticFileName(p)
+	if !valid {
+		return sh.notFound(ctx)
+	}
+
+	if original, fingerprinted := sh.original(name); fingerprinted {
+		return sh.serveFile(ctx, original, immutableCacheControl)
+	}
+
+	info, err := fs.Stat(sh.fsys, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return sh.notFound(ctx)
+	}
+	if err != nil {
+		return
+	}
+
+	if !info.IsDir() {
+		return sh.serveFile(ctx, name, engine.Config.cacheControl(name))
+	}
+
+	index := path.Join(name, sh.opts.Index)
+	_, err = fs.Stat(sh.fsys, index)
+	hasIndex := err == nil
+	if !hasIndex && !sh.opts.Browse {
+		return sh.notFound(ctx)
+	}
+
+	// redirect to the path with a trailing '/', so that relative links in the index or listing resolve under the directory
+	if urlPath := ctx.Request.URL.Path; !strings.HasSuffix(urlPath, "/") {
+		target := path.Base(urlPath) + "/"
+		if query := ctx.Request.URL.RawQuery; query != "" {
+			target += "?" + query
+		}
+		http.Redirect(&ctx.Response, ctx.Request, target, http.StatusMovedPermanently)
+		return nil
+	}
+
+	if hasIndex {
+		return sh.serveFile(ctx, index, engine.Config.cacheControl(index))
+	}
+
+	return sh.listDir(ctx, name)
+}
+
+// notFound sends the fallback file, if there is one, or responds with `http.StatusNotFound`.
+func (sh *staticHandler) notFound(ctx *Ctx) error {
+	if sh.opts.Fallback != "" {
+		if name, valid := staticFileName(sh.opts.Fallback); valid {
+			if info, err := fs.Stat(sh.fsys, name); err == nil && !info.IsDir() {
+				return sh.serveFile(ctx, name, engine.Config.cacheControl(name))
+			}
+		}
+	}
+
+	return NewProblem(http.StatusNotFound, "")
+}
+
+// serveFile sends the file `name` to the client with the given Cache-Control header, if not empty. If the client accepts it,
+// a precompressed sibling of the file (e.g. app.js.br or app.js.gz) is sent instead. The file is streamed, and Range requests,
+// conditional requests (If-None-Match, If-Modified-Since, ...) and HEAD requests are handled by http.ServeContent.
+func (sh *staticHandler) serveFile(ctx *Ctx, name string, cacheControl string) (err error) {
+	file, coding, err := sh.open(name, ctx.Request.Header.Get("Accept-Encoding"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return NewProblem(http.StatusNotFound, "")
+	}
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return
+	}
+
+	// files that can't seek are read into memory, which is fine since they're usually small (e.g. of an in-memory fs.FS)
+	content, seekable := file.(io.ReadSeeker)
+	if !seekable {
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return err
+		}
+		content = bytes.NewReader(data)
+	}
+
+	h := ctx.Response.Header()
+	h.Set("Content-Type", getContentType(name))
+	addVary(h, "Accept-Encoding")
+	if coding != "" {
+		h.Set("Content-Encoding", coding)
+	}
+
+	etag, err := sh.etag(name+coding, info, content)
+	if err != nil {
+		return
+	}
+	h.Set("ETag", etag)
+
+	if cacheControl != "" {
+		h.Set("Cache-Control", cacheControl)
+	}
+
+	http.ServeContent(&ctx.Response, ctx.Request, name, info.ModTime(), content)
+	return
+}
+
+// open opens the file `name`, or the precompressed sibling of it that the Accept-Encoding header acceptEncoding
+// prefers. The content coding of the opened file is returned along with it, which is empty for `name` itself.
+func (sh *staticHandler) open(name string, acceptEncoding string) (file fs.File, coding string, err error) {
+	if acceptEncoding != "" {
+		best := 0.0
+		for _, enc := range precompressedEncodings {
+			q := acceptEncodingQuality(acceptEncoding, enc.coding)
+			if q <= best {
+				continue
+			}
+
+			f, err := sh.fsys.Open(name + enc.ext)
+			if err != nil {
+				continue
+			}
+			if info, err := f.Stat(); err != nil || info.IsDir() {
+				f.Close()
+				continue
+			}
+
+			if file != nil {
+				file.Close()
+			}
+			file, coding, best = f, enc.coding, q
+		}
+
+		if file != nil {
+			return
+		}
+	}
+
+	file, err = sh.fsys.Open(name)
+	return
+}
+
+// dirListing renders the listing of a directory
+var dirListing = template.Must(template.New("listing").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Index of {{.Path}}</title></head>
+<body>
+<h1>Index of {{.Path}}</h1>
+<ul>
+{{- if not .Root}}
+<li><a href="../">../</a></li>
+{{- end}}
+{{- range .Entries}}
+<li><a href="{{.Href}}">{{.Name}}</a></li>
+{{- end}}
+</ul>
+</body>
+</html>
+`))
+
+// listDir sends a listing of the contents of the directory `name` to the client.
+func (sh *staticHandler) listDir(ctx *Ctx, name string) (err error) {
+	entries, err := fs.ReadDir(sh.fsys, name)
+	if err != nil {
+		return
+	}
+
+	type entry struct{ Name, Href string }
+	listing := struct {
+		Path    string
+		Root    bool // Root is true for the directory the files are served from, which has no parent to link to
+		Entries []entry
+	}{Path: ctx.Request.URL.Path, Root: name == "."}
+
+	for _, e := range entries {
+		entryName := e.Name()
+		if e.IsDir() {
+			entryName += "/"
+		}
+		listing.Entries = append(listing.Entries, entry{Name: entryName, Href: (&url.URL{Path: entryName}).String()})
+	}
+
+	var buf bytes.Buffer
+	err = dirListing.Execute(&buf, listing)
+	if err != nil {
+		return
+	}
+
+	ctx.Response.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, err = ctx.Response.Write(buf.Bytes())
+	return
+}
+
+// staticFileName turns the requested path p into the name of a file of the file system. The path is unescaped and
+// cleaned, so that it can't point outside of the file system (e.g. with "../"). If it's not a valid name `valid` will be false.
+func staticFileName(p string) (name string, valid bool) {
+	unescaped, err := url.PathUnescape(p)
+	if err != nil {
+		return "", false
+	}
+
+	name = strings.TrimPrefix(path.Clean("/"+unescaped), "/")
+	if name == "" {
+		name = "."
+	}
+
+	return name, fs.ValidPath(name) && !strings.ContainsAny(name, "\\\x00")
+}
+
+// etag returns the ETag of the file `name`. It's derived from the size and modification time of the file if it has one,
+// otherwise the content of the file is hashed once and the result is kept for the next requests.
+func (sh *staticHandler) etag(name string, info fs.FileInfo, content io.ReadSeeker) (string, error) {
+	if !info.ModTime().IsZero() {
+		return `"` + strconv.FormatInt(info.Size(), 16) + "-" + strconv.FormatInt(info.ModTime().UnixNano(), 16) + `"`, nil
+	}
+
+	if etag, cached := sh.etags.Load(name); cached {
+		return etag.(string), nil
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	etag := `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+	sh.etags.Store(name, etag)
+	return etag, nil
+}
@@ -0,0 +1,605 @@
+package goster
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+type StaticCase struct {
+	name           string
+	method         string // method is the method of the request, GET if empty
+	url            string
+	headers        map[string]string
+	expectedStatus int
+	expectedBody   string
+	expectedHeader map[string]string
+}
+
+func TestStaticServing(t *testing.T) {
+	g := NewServer()
+	dir := t.TempDir()
+	modTime := time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC)
+	if err := os.WriteFile(filepath.Join(dir, "video.mp4"), []byte("0123456789"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filepath.Join(dir, "video.mp4"), modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Routes.prepareStaticRoutes("/serve-disk", os.DirFS(dir), StaticOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Routes.prepareStaticRoutes("/serve-mem", fstest.MapFS{"css/app.css": {Data: []byte("body{}")}}, StaticOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	_ = g.CacheControl("*.css", "public, max-age=86400")
+	_ = g.CacheControl("video*", "no-store")
+
+	// the ETags are needed for the conditional requests
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/serve-disk/video.mp4", nil))
+	diskETag := rec.Header().Get("ETag")
+	rec = httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/serve-mem/css/app.css", nil))
+	memETag := rec.Header().Get("ETag")
+	if diskETag == "" || memETag == "" {
+		t.Fatalf("expected ETags, got %q and %q", diskETag, memETag)
+	}
+
+	testCases := []StaticCase{
+		{
+			name:           "Full file",
+			url:            "/serve-disk/video.mp4",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "0123456789",
+			expectedHeader: map[string]string{"Content-Length": "10", "Accept-Ranges": "bytes", "Last-Modified": modTime.Format(http.TimeFormat), "Cache-Control": "no-store"},
+		},
+		{
+			name:           "HEAD",
+			method:         http.MethodHead,
+			url:            "/serve-disk/video.mp4",
+			expectedStatus: http.StatusOK,
+			expectedHeader: map[string]string{"Content-Length": "10", "Accept-Ranges": "bytes", "Cache-Control": "no-store"},
+		},
+		{
+			name:           "Single range",
+			url:            "/serve-disk/video.mp4",
+			headers:        map[string]string{"Range": "bytes=2-5"},
+			expectedStatus: http.StatusPartialContent,
+			expectedBody:   "2345",
+			expectedHeader: map[string]string{"Content-Range": "bytes 2-5/10", "Content-Length": "4"},
+		},
+		{
+			name:           "Multiple ranges",
+			url:            "/serve-disk/video.mp4",
+			headers:        map[string]string{"Range": "bytes=0-1,8-9"},
+			expectedStatus: http.StatusPartialContent,
+			expectedHeader: map[string]string{"Content-Type": "multipart/byteranges; boundary="},
+		},
+		{
+			name:           "Unsatisfiable range",
+			url:            "/serve-disk/video.mp4",
+			headers:        map[string]string{"Range": "bytes=20-30"},
+			expectedStatus: http.StatusRequestedRangeNotSatisfiable,
+		},
+		{
+			name:           "If-None-Match",
+			url:            "/serve-disk/video.mp4",
+			headers:        map[string]string{"If-None-Match": diskETag},
+			expectedStatus: http.StatusNotModified,
+		},
+		{
+			name:           "If-Modified-Since",
+			url:            "/serve-disk/video.mp4",
+			headers:        map[string]string{"If-Modified-Since": modTime.Add(time.Hour).Format(http.TimeFormat)},
+			expectedStatus: http.StatusNotModified,
+		},
+		{
+			name:           "Modified since",
+			url:            "/serve-disk/video.mp4",
+			headers:        map[string]string{"If-Modified-Since": modTime.Add(-time.Hour).Format(http.TimeFormat)},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "0123456789",
+		},
+		{
+			name:           "Escaped path",
+			url:            "/serve-disk/video%2emp4",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "0123456789",
+		},
+		{
+			name:           "Path traversal",
+			url:            "/serve-disk/../static_test.go",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Escaped path traversal",
+			url:            "/serve-disk/%2e%2e/%2e%2e/static_test.go",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Missing file",
+			url:            "/serve-disk/missing.mp4",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Directory",
+			url:            "/serve-disk",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "File without modification time",
+			url:            "/serve-mem/css/app.css",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "body{}",
+			expectedHeader: map[string]string{"Content-Type": "text/css; charset=utf-8", "Cache-Control": "public, max-age=86400", "ETag": memETag},
+		},
+		{
+			name:           "If-None-Match on hashed ETag",
+			url:            "/serve-mem/css/app.css",
+			headers:        map[string]string{"If-None-Match": memETag},
+			expectedStatus: http.StatusNotModified,
+		},
+	}
+
+	failedCases := make(map[int]StaticCase, 0)
+	for i, c := range testCases {
+		method := c.method
+		if method == "" {
+			method = http.MethodGet
+		}
+		req := httptest.NewRequest(method, c.url, nil)
+		for k, v := range c.headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		g.ServeHTTP(rec, req)
+
+		failed := rec.Code != c.expectedStatus || (c.expectedBody != "" && rec.Body.String() != c.expectedBody)
+		for k, v := range c.expectedHeader {
+			if !strings.HasPrefix(rec.Header().Get(k), v) {
+				failed = true
+			}
+		}
+
+		if failed {
+			failedCases[i] = c
+			t.Errorf("FAILED [%d] - %s: got %d %q %v", i, c.name, rec.Code, rec.Body.String(), rec.Header())
+		} else {
+			t.Logf("PASSED [%d] - %s\n", i, c.name)
+		}
+	}
+
+	t.Logf("TOTAL CASES: %d\n", len(testCases))
+	t.Logf("FAILED CASES: %d\n", len(failedCases))
+}
+
+func TestStaticLiveUpdates(t *testing.T) {
+	g := NewServer()
+	dir := t.TempDir()
+	if err := g.Routes.prepareStaticRoutes("/serve-live", os.DirFS(dir), StaticOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func() int {
+		rec := httptest.NewRecorder()
+		g.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/serve-live/new.txt", nil))
+		return rec.Code
+	}
+
+	if code := get(); code != http.StatusNotFound {
+		t.Errorf("expected 404 before the file is created, got %d", code)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "new.txt"), []byte("new"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if code := get(); code != http.StatusOK {
+		t.Errorf("expected 200 after the file is created, got %d", code)
+	}
+	if err := os.Remove(filepath.Join(dir, "new.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if code := get(); code != http.StatusNotFound {
+		t.Errorf("expected 404 after the file is deleted, got %d", code)
+	}
+}
+
+func TestStaticFSMounts(t *testing.T) {
+	g := NewServer()
+	prefix := engine.Config.StaticPrefix
+	defer func() { engine.Config.StaticPrefix = prefix }()
+
+	if err := g.StaticFS("/mount-a", fstest.MapFS{"x.css": {Data: []byte("a")}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.StaticFS("/mount-b", fstest.MapFS{"x.css": {Data: []byte("b")}}); err != nil {
+		t.Fatalf("expected a second mount with the same file names to work, got %v", err)
+	}
+
+	for url, expected := range map[string]string{"/mount-a/x.css": "a", "/mount-b/x.css": "b"} {
+		rec := httptest.NewRecorder()
+		g.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != expected {
+			t.Errorf("expected %s to serve %q, got %d %q", url, expected, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestStaticDirMounts(t *testing.T) {
+	g := NewServer()
+	staticDir, prefix := engine.Config.StaticDir, engine.Config.StaticPrefix
+	defer func() { engine.Config.StaticDir, engine.Config.StaticPrefix = staticDir, prefix }()
+
+	dirs := []string{t.TempDir(), t.TempDir()}
+	for i, dir := range dirs {
+		if err := os.WriteFile(filepath.Join(dir, "x.css"), []byte{'a' + byte(i)}, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := g.StaticDir(dir); err != nil {
+			t.Fatalf("expected directories with the same file names to be mounted, got %v", err)
+		}
+	}
+	if err := g.StaticFS("/dir-mounts", fstest.MapFS{"x.css": {Data: []byte("c")}}); err != nil {
+		t.Fatalf("expected a file system with the same file names to be mounted, got %v", err)
+	}
+
+	urls := map[string]string{
+		filepath.ToSlash(dirs[0]) + "/x.css": "a",
+		filepath.ToSlash(dirs[1]) + "/x.css": "b",
+		"/dir-mounts/x.css":                  "c",
+	}
+	for url, expected := range urls {
+		rec := httptest.NewRecorder()
+		g.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != expected {
+			t.Errorf("expected %s to serve %q, got %d %q", url, expected, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestStaticOptions(t *testing.T) {
+	g := NewServer()
+	fsys := fstest.MapFS{
+		"app.js":          {Data: []byte("plain")},
+		"app.js.br":       {Data: []byte("brotli")},
+		"app.js.gz":       {Data: []byte("gzipped")},
+		"style.css":       {Data: []byte("body{}")},
+		"index.html":      {Data: []byte("<h1>home</h1>")},
+		"docs/index.html": {Data: []byte("<h1>docs</h1>")},
+		"files/a.txt":     {Data: []byte("a")},
+		"files/sub/b.txt": {Data: []byte("b")},
+	}
+	if err := g.Routes.prepareStaticRoutes("/serve-opts", fsys, StaticOptions{Browse: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Routes.prepareStaticRoutes("/serve-spa", fsys, StaticOptions{Fallback: "index.html"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Routes.prepareStaticRoutes("/serve-browse", fstest.MapFS{"a.txt": {Data: []byte("a")}}, StaticOptions{Browse: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []StaticCase{
+		{
+			name:           "Brotli preferred",
+			url:            "/serve-opts/app.js",
+			headers:        map[string]string{"Accept-Encoding": "gzip, br"},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "brotli",
+			expectedHeader: map[string]string{"Content-Encoding": "br", "Vary": "Accept-Encoding", "Content-Type": "text/javascript"},
+		},
+		{
+			name:           "Gzip by quality",
+			url:            "/serve-opts/app.js",
+			headers:        map[string]string{"Accept-Encoding": "br;q=0.5, gzip"},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "gzipped",
+			expectedHeader: map[string]string{"Content-Encoding": "gzip"},
+		},
+		{
+			name:           "No accepted encoding",
+			url:            "/serve-opts/app.js",
+			headers:        map[string]string{"Accept-Encoding": "br;q=0, deflate"},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "plain",
+			expectedHeader: map[string]string{"Vary": "Accept-Encoding"},
+		},
+		{
+			name:           "No precompressed sibling",
+			url:            "/serve-opts/style.css",
+			headers:        map[string]string{"Accept-Encoding": "br, gzip"},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "body{}",
+		},
+		{
+			name:           "Directory index",
+			url:            "/serve-opts/docs/",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "<h1>docs</h1>",
+		},
+		{
+			name:           "Directory redirect",
+			url:            "/serve-opts/docs",
+			expectedStatus: http.StatusMovedPermanently,
+			expectedHeader: map[string]string{"Location": "/serve-opts/docs/"},
+		},
+		{
+			name:           "Directory redirect keeps the query",
+			url:            "/serve-opts/docs?lang=en",
+			expectedStatus: http.StatusMovedPermanently,
+			expectedHeader: map[string]string{"Location": "/serve-opts/docs/?lang=en"},
+		},
+		{
+			name:           "HEAD directory index",
+			method:         http.MethodHead,
+			url:            "/serve-opts/docs/",
+			expectedStatus: http.StatusOK,
+			expectedHeader: map[string]string{"Content-Length": "13", "Content-Type": "text/html"},
+		},
+		{
+			name:           "Directory listing",
+			url:            "/serve-opts/files/",
+			expectedStatus: http.StatusOK,
+			expectedHeader: map[string]string{"Content-Type": "text/html"},
+		},
+		{
+			name:           "SPA fallback",
+			url:            "/serve-spa/users/42",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "<h1>home</h1>",
+		},
+		{
+			name:           "HEAD SPA fallback",
+			method:         http.MethodHead,
+			url:            "/serve-spa/users/42",
+			expectedStatus: http.StatusOK,
+			expectedHeader: map[string]string{"Content-Length": "13", "Content-Type": "text/html"},
+		},
+		{
+			name:           "SPA existing file",
+			url:            "/serve-spa/style.css",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "body{}",
+		},
+		{
+			name:           "Missing file without fallback",
+			url:            "/serve-opts/users/42",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	failedCases := make(map[int]StaticCase, 0)
+	for i, c := range testCases {
+		method := c.method
+		if method == "" {
+			method = http.MethodGet
+		}
+		req := httptest.NewRequest(method, c.url, nil)
+		for k, v := range c.headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		g.ServeHTTP(rec, req)
+
+		failed := rec.Code != c.expectedStatus || (c.expectedBody != "" && rec.Body.String() != c.expectedBody)
+		for k, v := range c.expectedHeader {
+			if !strings.HasPrefix(rec.Header().Get(k), v) {
+				failed = true
+			}
+		}
+
+		if failed {
+			failedCases[i] = c
+			t.Errorf("FAILED [%d] - %s: got %d %q %v", i, c.name, rec.Code, rec.Body.String(), rec.Header())
+		} else {
+			t.Logf("PASSED [%d] - %s\n", i, c.name)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/serve-opts/files/", nil))
+	if body := rec.Body.String(); !strings.Contains(body, `<a href="a.txt">a.txt</a>`) || !strings.Contains(body, `<a href="sub/">sub/</a>`) ||
+		!strings.Contains(body, `<a href="../">../</a>`) {
+		t.Errorf("expected the listing to link to the entries and the parent of the directory, got %q", body)
+	}
+
+	rec = httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/serve-opts/files/sub/", nil))
+	if body := rec.Body.String(); !strings.Contains(body, `<a href="b.txt">b.txt</a>`) || !strings.Contains(body, `<a href="../">../</a>`) {
+		t.Errorf("expected the nested listing to link to its entries and its parent, got %q", body)
+	}
+
+	rec = httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/serve-browse/", nil))
+	if body := rec.Body.String(); !strings.Contains(body, `<a href="a.txt">a.txt</a>`) || strings.Contains(body, "../") {
+		t.Errorf("expected the listing of the root to have no parent link, got %q", body)
+	}
+
+	t.Logf("TOTAL CASES: %d\n", len(testCases))
+	t.Logf("FAILED CASES: %d\n", len(failedCases))
+}
+
+func TestStaticFingerprint(t *testing.T) {
+	g := NewServer()
+	fsys := fstest.MapFS{
+		"css/app.css":    {Data: []byte("body{}")},
+		"js/app.js":      {Data: []byte("plain")},
+		"js/app.js.br":   {Data: []byte("brotli")},
+		"robots.txt":     {Data: []byte("User-agent: *")},
+		"images/logo":    {Data: []byte("png")},
+		"images/logo.gz": {Data: []byte("gzipped")},
+	}
+	if err := g.StaticFS("/fingerprinted", fsys, StaticOptions{Fingerprint: true}); err != nil {
+		t.Fatal(err)
+	}
+	_ = g.CacheControl("*.css", "no-cache")
+
+	css := g.Asset("css/app.css")
+	if !regexp.MustCompile(`^/fingerprinted/css/app\.[0-9a-f]{8}\.css$`).MatchString(css) {
+		t.Fatalf("expected a fingerprinted URL, got %q", css)
+	}
+	if u := g.Asset("js/app.js.br"); u != "/fingerprinted/js/app.js.br" {
+		t.Errorf("expected precompressed siblings not to be fingerprinted")
+	}
+	if logo := g.Asset("/images/logo"); !regexp.MustCompile(`^/fingerprinted/images/logo\.[0-9a-f]{8}$`).MatchString(logo) {
+		t.Errorf("expected a fingerprinted URL for a file without extension, got %q", logo)
+	}
+	if u := g.Asset("missing.css"); u != "/fingerprinted/missing.css" {
+		t.Errorf("expected the plain URL of an unknown file, got %q", u)
+	}
+
+	testCases := []StaticCase{
+		{
+			name:           "Fingerprinted file",
+			url:            css,
+			expectedStatus: http.StatusOK,
+			expectedBody:   "body{}",
+			expectedHeader: map[string]string{"Cache-Control": immutableCacheControl, "Content-Type": "text/css"},
+		},
+		{
+			name:           "Fingerprinted file with precompressed sibling",
+			url:            g.Asset("js/app.js"),
+			headers:        map[string]string{"Accept-Encoding": "br"},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "brotli",
+			expectedHeader: map[string]string{"Cache-Control": immutableCacheControl, "Content-Encoding": "br"},
+		},
+		{
+			name:           "Original name",
+			url:            "/fingerprinted/css/app.css",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "body{}",
+			expectedHeader: map[string]string{"Cache-Control": "no-cache"},
+		},
+		{
+			name:           "Wrong hash",
+			url:            "/fingerprinted/css/app.00000000.css",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	failedCases := make(map[int]StaticCase, 0)
+	for i, c := range testCases {
+		method := c.method
+		if method == "" {
+			method = http.MethodGet
+		}
+		req := httptest.NewRequest(method, c.url, nil)
+		for k, v := range c.headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		g.ServeHTTP(rec, req)
+
+		failed := rec.Code != c.expectedStatus || (c.expectedBody != "" && rec.Body.String() != c.expectedBody)
+		for k, v := range c.expectedHeader {
+			if !strings.HasPrefix(rec.Header().Get(k), v) {
+				failed = true
+			}
+		}
+
+		if failed {
+			failedCases[i] = c
+			t.Errorf("FAILED [%d] - %s: got %d %q %v", i, c.name, rec.Code, rec.Body.String(), rec.Header())
+		} else {
+			t.Logf("PASSED [%d] - %s\n", i, c.name)
+		}
+	}
+
+	manifestPath := filepath.Join(t.TempDir(), "manifest.json")
+	if err := g.WriteManifest(manifestPath); err != nil {
+		t.Fatal(err)
+	}
+	content, _ := os.ReadFile(manifestPath)
+	manifest := map[string]string{}
+	err := json.Unmarshal(content, &manifest)
+	listed := 0
+	for name := range manifest {
+		if strings.HasPrefix(name, "/fingerprinted/") {
+			listed++
+		}
+	}
+	if err != nil || manifest["/fingerprinted/css/app.css"] != css || listed != 4 {
+		t.Errorf("expected the manifest to list the fingerprinted files, got %s (%v)", content, err)
+	}
+
+	t.Logf("TOTAL CASES: %d\n", len(testCases))
+	t.Logf("FAILED CASES: %d\n", len(failedCases))
+}
+
+func TestStaticFingerprintMounts(t *testing.T) {
+	g := NewServer()
+	prefix := engine.Config.StaticPrefix
+	defer func() { engine.Config.StaticPrefix = prefix }()
+
+	if err := g.StaticFS("/fingerprint-vendor", fstest.MapFS{"css/app.css": {Data: []byte("vendor")}}, StaticOptions{Fingerprint: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.StaticFS("/fingerprint-site", fstest.MapFS{"css/app.css": {Data: []byte("site")}}, StaticOptions{Fingerprint: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	vendor, site := g.Asset("css/app.css"), g.Asset("/fingerprint-site/css/app.css")
+	if !regexp.MustCompile(`^/fingerprint-vendor/css/app\.[0-9a-f]{8}\.css$`).MatchString(vendor) {
+		t.Errorf("expected relative paths to resolve against the first mount, got %q", vendor)
+	}
+	if !regexp.MustCompile(`^/fingerprint-site/css/app\.[0-9a-f]{8}\.css$`).MatchString(site) {
+		t.Errorf("expected the file of the other mount by its full path, got %q", site)
+	}
+
+	for url, expected := range map[string]string{site: "site", vendor: "vendor"} {
+		rec := httptest.NewRecorder()
+		g.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != expected {
+			t.Errorf("expected %s to serve %q, got %d %q", url, expected, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestStaticFingerprintChanges(t *testing.T) {
+	g := NewServer()
+	prefix := engine.Config.StaticPrefix
+	defer func() { engine.Config.StaticPrefix = prefix }()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.css")
+	if err := os.WriteFile(file, []byte("body{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.StaticFS("/fingerprint-changes", os.DirFS(dir), StaticOptions{Fingerprint: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(url string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		g.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		return rec
+	}
+
+	old := g.Asset("/fingerprint-changes/app.css")
+	if rec := get(old); rec.Code != http.StatusOK || rec.Body.String() != "body{}" {
+		t.Fatalf("expected %s to serve the file, got %d %q", old, rec.Code, rec.Body.String())
+	}
+
+	if err := os.WriteFile(file, []byte("body{color:red}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if rec := get(old); rec.Code != http.StatusNotFound {
+		t.Errorf("expected the old fingerprinted name to stop being served once the file changed, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	current := g.Asset("/fingerprint-changes/app.css")
+	if current == old {
+		t.Fatalf("expected the changed file to get a new fingerprinted name, got %s again", current)
+	}
+	if rec := get(current); rec.Code != http.StatusOK || rec.Body.String() != "body{color:red}" || rec.Header().Get("Cache-Control") != immutableCacheControl {
+		t.Errorf("expected %s to serve the new content as immutable, got %d %q %v", current, rec.Code, rec.Body.String(), rec.Header())
+	}
+}
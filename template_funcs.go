@@ -0,0 +1,288 @@
+package goster
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"html/template"
+	"maps"
+	"net/url"
+	"strings"
+	"text/template/parse"
+	"time"
+)
+
+// CSRFTokenKey is the key under which CSRF middleware should store the token of the request with Ctx.Set,
+// so that the `csrfField` template helper can render it.
+const CSRFTokenKey = "goster.csrf_token"
+
+// CSRFFieldName is the name of the hidden form field rendered by the `csrfField` template helper.
+const CSRFFieldName = "csrf_token"
+
+// TemplateFuncs registers funcMap for all templates rendered through Ctx. Functions with the same name as
+// previously registered ones (or built-in helpers) replace them. Already parsed templates are parsed again,
+// so that they can use the new functions; any errors while doing so are returned.
+//
+// Besides the registered functions, every template can use the following built-in helpers:
+//
+//	url "routeName" params   the path of a named route, see Goster.URL
+//	asset "css/app.css"      the URL of a file of the static directory, fingerprinted if enabled (see Goster.Asset)
+//	csrfField                a hidden form field with the CSRF token of the request (see CSRFTokenKey)
+//	json value               value encoded as JSON
+//	safeHTML "<b>hi</b>"     the string marked as safe HTML, so that it isn't escaped
+//	date "2006-01-02" t      the time.Time t formatted with the given layout
+//	dict "key" value ...     a map made of the given key/value pairs
+func (g *Goster) TemplateFuncs(funcMap template.FuncMap) error {
+	if engine.templateFuncs == nil {
+		engine.templateFuncs = make(template.FuncMap, len(funcMap))
+	}
+	maps.Copy(engine.templateFuncs, funcMap)
+
+	if len(engine.Config.TemplatePaths) == 0 {
+		return nil
+	}
+
+	return engine.parseTemplates()
+}
+
+// funcMap returns the built-in helpers along with the registered functions and the functions of extra.
+func (e *Engine) funcMap(extra template.FuncMap) template.FuncMap {
+	funcMap := template.FuncMap{
+		"url":       templateURL,
+		"asset":     templateAsset,
+		"csrfField": templateCSRFField,
+		"json":      templateJSON,
+		"safeHTML":  templateSafeHTML,
+		"date":      templateDate,
+		"dict":      templateDict,
+	}
+	maps.Copy(funcMap, e.templateFuncs)
+	maps.Copy(funcMap, extra)
+
+	return funcMap
+}
+
+// NameRoute gives the route with the given path a name, so that its URL can be built with Goster.URL
+// or the `url` template helper. If there's no route with that path an error is returned.
+func (g *Goster) NameRoute(name string, path string) error {
+	cleanPath(&path)
+	for m := range g.Routes {
+		if _, exists := g.Routes[m][path]; exists {
+			if g.routeNames == nil {
+				g.routeNames = make(map[string]string)
+			}
+			g.routeNames[name] = path
+			return nil
+		}
+	}
+
+	return fmt.Errorf("route `%s` doesn't exist", path)
+}
+
+// URL builds the URL of the route named `name` (see Goster.NameRoute). Instead of a name, the path of a route can
+// be given too, as long as it starts with '/'. The values of the dynamic segments are taken from params, which is
+// either a single map or a list of key/value pairs. Any params that don't match a dynamic segment are added to the query string.
+//
+//	g.NameRoute("book", "/users/:id/books/:book")
+//	g.URL("book", "id", 42, "book", 7, "page", 2) // "/users/42/books/7?page=2"
+func (g *Goster) URL(name string, params ...any) (string, error) {
+	routePath, exists := g.routeNames[name]
+	if !exists {
+		if !strings.HasPrefix(name, "/") {
+			return "", fmt.Errorf("route named `%s` doesn't exist", name)
+		}
+		routePath = name
+	}
+
+	values, err := urlParams(params)
+	if err != nil {
+		return "", err
+	}
+
+	segments := strings.Split(routePath, "/")
+	for i, seg := range segments {
+		if !strings.HasPrefix(seg, ":") {
+			continue
+		}
+
+		value, exists := values[seg[1:]]
+		if !exists {
+			return "", fmt.Errorf("missing value for `%s` of route `%s`", seg, routePath)
+		}
+		segments[i] = url.PathEscape(value)
+		delete(values, seg[1:])
+	}
+
+	u := strings.Join(segments, "/")
+	if u == "" {
+		u = "/"
+	}
+	if len(values) > 0 {
+		query := url.Values{}
+		for k, v := range values {
+			query.Set(k, v)
+		}
+		u += "?" + query.Encode()
+	}
+
+	return u, nil
+}
+
+// urlParams turns the params of Goster.URL into a map
+func urlParams(params []any) (map[string]string, error) {
+	values := make(map[string]string)
+	if len(params) == 1 {
+		switch m := params[0].(type) {
+		case map[string]string:
+			maps.Copy(values, m)
+			return values, nil
+		case map[string]any:
+			for k, v := range m {
+				values[k] = fmt.Sprint(v)
+			}
+			return values, nil
+		}
+	}
+
+	pairs, err := templateDict(params...)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range pairs {
+		values[k] = fmt.Sprint(v)
+	}
+
+	return values, nil
+}
+
+func templateURL(name string, params ...any) (string, error) {
+	return engine.Goster.URL(name, params...)
+}
+
+func templateAsset(p string) string {
+	return engine.Goster.Asset(p)
+}
+
+// templateCSRFField renders nothing. Sets that use `csrfField` get it replaced with the field of the request
+// when it has a CSRF token, see Ctx.withCSRFField.
+func templateCSRFField() template.HTML {
+	return ""
+}
+
+func templateJSON(v any) (template.JS, error) {
+	b, err := json.Marshal(v)
+	return template.JS(b), err
+}
+
+func templateSafeHTML(s string) template.HTML {
+	return template.HTML(s)
+}
+
+func templateDate(layout string, t any) (string, error) {
+	switch v := t.(type) {
+	case time.Time:
+		if v.IsZero() {
+			return "", nil
+		}
+		return v.Format(layout), nil
+	case *time.Time:
+		if v == nil || v.IsZero() {
+			return "", nil
+		}
+		return v.Format(layout), nil
+	}
+
+	return "", fmt.Errorf("date: expected a time.Time, got %T", t)
+}
+
+func templateDict(pairs ...any) (map[string]any, error) {
+	if len(pairs)%2 != 0 {
+		return nil, fmt.Errorf("dict: expected key/value pairs, got %d arguments", len(pairs))
+	}
+
+	dict := make(map[string]any, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("dict: keys must be strings, got %T", pairs[i])
+		}
+		dict[key] = pairs[i+1]
+	}
+
+	return dict, nil
+}
+
+// withCSRFField returns a copy of the set tmpl whose `csrfField` renders the CSRF field of the request, or nil if
+// the request has no CSRF token. The token is bound through the functions of the copy, so it can't end up anywhere
+// but where `csrfField` is called. tmpl must not have been executed, since executed sets can't be cloned.
+func (c *Ctx) withCSRFField(tmpl *template.Template) (*template.Template, error) {
+	token, exists := GetAs[string](c, CSRFTokenKey)
+	if !exists {
+		return nil, nil
+	}
+
+	clone, err := tmpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	field := template.HTML(`<input type="hidden" name="` + CSRFFieldName + `" value="` + html.EscapeString(token) + `">`)
+	return clone.Funcs(template.FuncMap{"csrfField": func() template.HTML { return field }}), nil
+}
+
+// usesFunc reports whether any template of the set tmpl calls the function fn
+func usesFunc(tmpl *template.Template, fn string) bool {
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil && nodeUsesFunc(t.Tree.Root, fn) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// nodeUsesFunc reports whether fn is called anywhere under node
+func nodeUsesFunc(node parse.Node, fn string) bool {
+	switch n := node.(type) {
+	case *parse.IdentifierNode:
+		return n.Ident == fn
+	case *parse.ListNode:
+		if n == nil {
+			return false
+		}
+		for _, child := range n.Nodes {
+			if nodeUsesFunc(child, fn) {
+				return true
+			}
+		}
+	case *parse.ActionNode:
+		return nodeUsesFunc(n.Pipe, fn)
+	case *parse.PipeNode:
+		if n == nil {
+			return false
+		}
+		for _, cmd := range n.Cmds {
+			if nodeUsesFunc(cmd, fn) {
+				return true
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			if nodeUsesFunc(arg, fn) {
+				return true
+			}
+		}
+	case *parse.ChainNode:
+		return nodeUsesFunc(n.Node, fn)
+	case *parse.IfNode:
+		return nodeUsesFunc(n.Pipe, fn) || nodeUsesFunc(n.List, fn) || nodeUsesFunc(n.ElseList, fn)
+	case *parse.RangeNode:
+		return nodeUsesFunc(n.Pipe, fn) || nodeUsesFunc(n.List, fn) || nodeUsesFunc(n.ElseList, fn)
+	case *parse.WithNode:
+		return nodeUsesFunc(n.Pipe, fn) || nodeUsesFunc(n.List, fn) || nodeUsesFunc(n.ElseList, fn)
+	case *parse.TemplateNode:
+		return nodeUsesFunc(n.Pipe, fn)
+	}
+
+	return false
+}
@@ -0,0 +1,355 @@
+package goster
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrTemplateNotFound is returned when rendering a template that isn't in the template directory.
+var ErrTemplateNotFound = errors.New("goster: template not found")
+
+// TemplateError is returned when a template can't be parsed or executed. It points to the file and line
+// the error originated from, which may be a partial or layout used by the rendered template.
+type TemplateError struct {
+	Name string // Name is the name of the template the error originated from
+	File string // File is the path of the file of the template (empty if it isn't known)
+	Line int    // Line is the line of the file the error originated from (0 if it isn't known)
+	Err  error  // Err is the underlying error
+}
+
+func (e *TemplateError) Error() string {
+	location := e.Name
+	if e.File != "" {
+		location = e.File
+	}
+	if e.Line > 0 {
+		location += ":" + strconv.Itoa(e.Line)
+	}
+
+	return fmt.Sprintf("template %s: %s", location, e.Err)
+}
+
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+// templateErrLocation matches the location text/template puts at the start of its errors (e.g. "template: home.html:3:")
+var templateErrLocation = regexp.MustCompile(`^template: (.+?):(\d+):`)
+
+// newTemplateError wraps err, returned while parsing or executing the template `name`, in a TemplateError.
+func newTemplateError(name string, err error) *TemplateError {
+	var te *TemplateError
+	if errors.As(err, &te) {
+		return te
+	}
+
+	te = &TemplateError{Name: name, Err: err}
+
+	var escapeErr *template.Error
+	if errors.As(err, &escapeErr) && escapeErr.Name != "" {
+		te.Name, te.Line = escapeErr.Name, escapeErr.Line
+	} else if m := templateErrLocation.FindStringSubmatch(err.Error()); m != nil {
+		te.Name = m[1]
+		te.Line, _ = strconv.Atoi(m[2])
+	}
+
+	te.File, _ = engine.templatePath(te.Name)
+	return te
+}
+
+// maxRenderBufferSize is the capacity above which render buffers aren't reused, so that a single huge page
+// doesn't keep its memory around for good
+const maxRenderBufferSize = 64 << 10
+
+// renderBuffers keeps the buffers templates are rendered into around between requests
+var renderBuffers = sync.Pool{
+	New: func() any {
+		return new(bytes.Buffer)
+	},
+}
+
+// templateCache holds the parsed templates of the template directory so that they don't have to be parsed on every request.
+//
+// Every template gets its own set that also contains all the shared templates (the ones under one of
+// Config.SharedTemplateDirs, like layouts and partials), so that pages can include partials with
+// {{template "partials/nav.html" .}} and fill in the blocks of a layout with {{define}} without clashing with each other.
+//
+// In dev mode the template directory is watched and the cache is rebuilt on the next render after something changed in it.
+type templateCache struct {
+	mu       sync.RWMutex
+	sets     map[string]*template.Template // sets holds the parsed set of every template, keyed by its path relative to the template dir
+	csrfSets map[string]*template.Template // csrfSets holds unexecuted copies of the sets that use `csrfField`, cloned to bind the token of a request
+	errs     map[string]error              // errs holds the errors of the templates that couldn't be parsed
+	reloadMu sync.Mutex                    // reloadMu makes sure only one request reloads the templates at a time
+	watcher  watcher                       // watcher watches the template dir in dev mode
+}
+
+// lookup returns the parsed set of the template t. If t couldn't be parsed, the parse error is returned.
+//
+// If the specified template `t` isn't known `exists` will be false
+func (tc *templateCache) lookup(t string) (tmpl *template.Template, exists bool, err error) {
+	tc.mu.RLock()
+	defer tc.mu.RUnlock()
+
+	if err, failed := tc.errs[t]; failed {
+		return nil, true, err
+	}
+
+	tmpl, exists = tc.sets[t]
+	return
+}
+
+// csrfSet returns the unexecuted copy of the set of the template t if it uses `csrfField`, or nil otherwise.
+func (tc *templateCache) csrfSet(t string) *template.Template {
+	tc.mu.RLock()
+	defer tc.mu.RUnlock()
+
+	return tc.csrfSets[t]
+}
+
+// store replaces the cached sets and errors with the given ones.
+func (tc *templateCache) store(sets, csrfSets map[string]*template.Template, errs map[string]error) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	tc.sets = sets
+	tc.csrfSets = csrfSets
+	tc.errs = errs
+}
+
+// closeWatcher stops watching the template dir, if it's being watched.
+func (tc *templateCache) closeWatcher() {
+	tc.reloadMu.Lock()
+	defer tc.reloadMu.Unlock()
+
+	if tc.watcher != nil {
+		_ = tc.watcher.close()
+		tc.watcher = nil
+	}
+}
+
+// templatePath returns the path of the file of the template t.
+//
+// If the specified template `t` isn't known `exists` will be false
+func (e *Engine) templatePath(t string) (path string, exists bool) {
+	e.templates.mu.RLock()
+	defer e.templates.mu.RUnlock()
+
+	path, exists = e.Config.TemplatePaths[t]
+	return
+}
+
+// reloadTemplates walks the template directory again and re-parses all the templates if anything changed in it since
+// the last check. It only does so in dev mode, otherwise the templates parsed at startup are kept as they are.
+func (e *Engine) reloadTemplates() {
+	if !e.Config.DevMode || e.Config.BaseTemplateDir == "" {
+		return
+	}
+
+	e.templates.reloadMu.Lock()
+	defer e.templates.reloadMu.Unlock()
+
+	// when the watch starts, reload anyway since anything could have changed before
+	if e.templates.watcher == nil {
+		e.templates.watcher = newWatcher(e.Config.BaseTemplateDir)
+	} else if !e.templates.watcher.changed() {
+		return
+	}
+
+	templatePaths, err := walkTemplateDir(e.Config.BaseTemplateDir)
+	if err != nil {
+		e.logger().Warn("could not reload templates", "error", err)
+		return
+	}
+
+	e.templates.mu.Lock()
+	e.Config.TemplatePaths = templatePaths
+	e.templates.mu.Unlock()
+
+	err = e.parseTemplates()
+	if err != nil {
+		e.logger().Warn("could not reload templates", "error", err)
+		return
+	}
+	e.logger().Info("reloaded templates", "count", len(templatePaths), "dir", e.Config.BaseTemplateDir)
+}
+
+// parseTemplates parses every template in Config.TemplatePaths and stores the result in the template cache of the engine.
+// The templates that could be parsed are cached even if others couldn't. The errors of the rest are returned
+// as TemplateErrors and reported again whenever they're rendered.
+func (e *Engine) parseTemplates() error {
+	sets := make(map[string]*template.Template, len(e.Config.TemplatePaths))
+	csrfSets := make(map[string]*template.Template)
+	errs := make(map[string]error)
+
+	shared, err := e.parseSharedTemplates(nil)
+	if err != nil {
+		for t := range e.Config.TemplatePaths {
+			errs[t] = err
+		}
+		e.templates.store(sets, csrfSets, errs)
+		return err
+	}
+
+	parseErrs := []error{}
+	for t := range e.Config.TemplatePaths {
+		tmpl, err := e.parseTemplate(shared, t)
+		if err != nil {
+			errs[t] = err
+			parseErrs = append(parseErrs, err)
+			continue
+		}
+		sets[t] = tmpl
+
+		if usesFunc(tmpl, "csrfField") {
+			// executed sets can't be cloned anymore, so keep a copy around for binding CSRF tokens
+			csrfSets[t], err = tmpl.Clone()
+			if err != nil {
+				errs[t] = newTemplateError(t, err)
+				parseErrs = append(parseErrs, errs[t])
+				delete(sets, t)
+			}
+		}
+	}
+
+	e.templates.store(sets, csrfSets, errs)
+	return errors.Join(parseErrs...)
+}
+
+// parseSharedTemplates parses the templates under one of Config.SharedTemplateDirs into a single set
+// that has the built-in helpers, the registered functions and the functions of funcMap available.
+func (e *Engine) parseSharedTemplates(funcMap template.FuncMap) (shared *template.Template, err error) {
+	shared = template.New("").Funcs(e.funcMap(funcMap))
+	for t := range e.Config.TemplatePaths {
+		if !e.Config.isSharedTemplate(t) {
+			continue
+		}
+
+		err = parseTemplateFile(shared, t, e.Config.TemplatePaths[t])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return
+}
+
+// parseTemplate creates the set of the template t by adding it to a copy of the shared templates.
+func (e *Engine) parseTemplate(shared *template.Template, t string) (tmpl *template.Template, err error) {
+	tmpl, err = shared.Clone()
+	if err != nil {
+		return nil, newTemplateError(t, err)
+	}
+
+	// shared templates are already part of the set
+	if e.Config.isSharedTemplate(t) {
+		return
+	}
+
+	err = parseTemplateFile(tmpl, t, e.Config.TemplatePaths[t])
+	if err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// parseTemplateFile reads the file at path and parses it into set under the name `name`. Errors are returned as a TemplateError.
+func parseTemplateFile(set *template.Template, name, path string) error {
+	content, err := engine.readTemplate(path)
+	if err != nil {
+		return &TemplateError{Name: name, File: path, Err: err}
+	}
+
+	_, err = set.New(name).Parse(string(content))
+	if err != nil {
+		return newTemplateError(name, err)
+	}
+
+	return nil
+}
+
+// isSharedTemplate reports whether the template t is under one of the shared template directories.
+func (c *Config) isSharedTemplate(t string) bool {
+	for _, dir := range c.SharedTemplateDirs {
+		if strings.HasPrefix(t, strings.Trim(dir, "/")+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Render sends the template `page` to the client, executed with data. The template is looked up in the templates
+// parsed from the template directory by its path relative to it (e.g. "users/profile.html").
+//
+// If the page isn't in the template directory an error wrapping ErrTemplateNotFound is returned. If it can't be parsed
+// or executed a *TemplateError is returned. In both cases nothing is sent to the client, since the template is rendered
+// in full before sending it.
+//
+// If a layout is given, the layout is executed instead and the page fills in its blocks:
+//
+//	<!-- layouts/base.html -->
+//	<html><body>{{template "partials/nav.html" .}}{{block "content" .}}{{end}}</body></html>
+//
+//	<!-- users/profile.html -->
+//	{{define "content"}}<h1>{{.Name}}</h1>{{end}}
+//
+//	ctx.Render("users/profile.html", user, "layouts/base.html")
+func (c *Ctx) Render(page string, data any, layout ...string) (err error) {
+	engine.reloadTemplates()
+
+	tmpl, exists, err := engine.templates.lookup(page)
+	if err != nil {
+		return
+	}
+	if !exists {
+		return fmt.Errorf("%w: `%s`", ErrTemplateNotFound, page)
+	}
+	if csrfSet := engine.templates.csrfSet(page); csrfSet != nil {
+		bound, err := c.withCSRFField(csrfSet)
+		if err != nil {
+			return newTemplateError(page, err)
+		}
+		if bound != nil {
+			tmpl = bound
+		}
+	}
+
+	return c.execute(tmpl, page, data, layout...)
+}
+
+// execute executes the template `name` of tmpl with data and sends it to the client. If a layout is given, it's executed instead.
+// The output is buffered, so that nothing is sent if the execution fails half-way.
+func (c *Ctx) execute(tmpl *template.Template, name string, data any, layout ...string) (err error) {
+	if len(layout) > 0 && layout[0] != "" {
+		if tmpl.Lookup(layout[0]) == nil {
+			return fmt.Errorf("%w: layout `%s`", ErrTemplateNotFound, layout[0])
+		}
+		name = layout[0]
+	}
+
+	buf := renderBuffers.Get().(*bytes.Buffer)
+	defer func() {
+		if buf.Cap() > maxRenderBufferSize {
+			return
+		}
+		buf.Reset()
+		renderBuffers.Put(buf)
+	}()
+
+	err = tmpl.ExecuteTemplate(buf, name, data)
+	if err != nil {
+		return newTemplateError(name, err)
+	}
+
+	c.Response.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, err = c.Response.Write(buf.Bytes())
+	return
+}
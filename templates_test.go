@@ -0,0 +1,256 @@
+package goster
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// keepTemplateConfig restores the template configuration of the engine once the test is done, so that later tests
+// don't inherit templates from temporary directories that have been removed
+func keepTemplateConfig(t *testing.T) {
+	t.Helper()
+	baseDir, paths, prefix, fsys := engine.Config.BaseTemplateDir, maps.Clone(engine.Config.TemplatePaths), engine.Config.StaticPrefix, engine.templateFS
+
+	t.Cleanup(func() {
+		engine.templates.closeWatcher()
+		engine.Config.BaseTemplateDir, engine.Config.TemplatePaths, engine.Config.StaticPrefix, engine.templateFS = baseDir, paths, prefix, fsys
+		if err := engine.parseTemplates(); err != nil {
+			t.Errorf("could not restore the templates: %v", err)
+		}
+	})
+}
+
+// writeTemplates writes the given templates to a temporary directory and registers them with the engine
+func writeTemplates(t *testing.T, templates map[string]string) {
+	t.Helper()
+	keepTemplateConfig(t)
+	dir := t.TempDir()
+
+	for name, content := range templates {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		engine.Config.TemplatePaths[name] = path
+	}
+}
+
+type RenderCase struct {
+	name         string
+	page         string
+	layout       string
+	expectedBody string
+}
+
+func TestRender(t *testing.T) {
+	g := NewServer()
+	writeTemplates(t, map[string]string{
+		"layouts/base.html": `<title>{{block "title" .}}Default{{end}}</title>{{template "partials/nav.html" .}}{{block "content" .}}{{end}}`,
+		"partials/nav.html": `<nav>{{.}}</nav>`,
+		"home.html":         `{{define "title"}}Home{{end}}{{define "content"}}<h1>Hello {{.}}</h1>{{end}}`,
+		"about.html":        `{{define "content"}}<p>About {{.}}</p>{{end}}`,
+		"standalone.gohtml": `<p>{{template "partials/nav.html" .}} standalone</p>`,
+	})
+	if err := engine.parseTemplates(); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []RenderCase{
+		{"Page with layout", "home.html", "layouts/base.html", "<title>Home</title><nav>dimitris</nav><h1>Hello dimitris</h1>"},
+		{"Page with layout and default block", "about.html", "layouts/base.html", "<title>Default</title><nav>dimitris</nav><p>About dimitris</p>"},
+		{"Page with partial", "standalone.gohtml", "", "<p><nav>dimitris</nav> standalone</p>"},
+	}
+
+	failedCases := make(map[int]RenderCase, 0)
+	for i, c := range testCases {
+		route := "/render/" + c.name
+		_ = g.Get(strings.ReplaceAll(route, " ", "-"), func(ctx *Ctx) error {
+			return ctx.Render(c.page, "dimitris", c.layout)
+		})
+
+		rec := httptest.NewRecorder()
+		g.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, strings.ReplaceAll(route, " ", "-"), nil))
+		if rec.Body.String() != c.expectedBody {
+			failedCases[i] = c
+			t.Errorf("FAILED [%d] - %s: got %q", i, c.name, rec.Body.String())
+		} else {
+			t.Logf("PASSED [%d] - %s\n", i, c.name)
+		}
+	}
+
+	t.Logf("TOTAL CASES: %d\n", len(testCases))
+	t.Logf("FAILED CASES: %d\n", len(failedCases))
+}
+
+func TestRenderErrors(t *testing.T) {
+	g := NewServer()
+	writeTemplates(t, map[string]string{
+		"broken.html":     "<p>\n{{if .}}</p>",
+		"failing.html":    "<p>before</p>\n{{index . 5}}",
+		"partials/a.html": "ok",
+	})
+	err := engine.parseTemplates()
+
+	var te *TemplateError
+	if !errors.As(err, &te) || te.Name != "broken.html" || te.Line != 2 || te.File != engine.Config.TemplatePaths["broken.html"] {
+		t.Errorf("expected a parse error pointing to broken.html:2, got %v", err)
+	}
+
+	_ = g.Get("/render-errors/missing", func(ctx *Ctx) error {
+		err := ctx.Render("missing.html", nil)
+		if !errors.Is(err, ErrTemplateNotFound) {
+			t.Errorf("expected ErrTemplateNotFound, got %v", err)
+		}
+		return err
+	})
+	_ = g.Get("/render-errors/failing", func(ctx *Ctx) error {
+		err := ctx.Render("failing.html", []int{})
+		if !errors.As(err, &te) || te.Line != 2 {
+			t.Errorf("expected an execution error at line 2, got %v", err)
+		}
+		return err
+	})
+
+	for _, url := range []string{"/render-errors/missing", "/render-errors/failing"} {
+		rec := httptest.NewRecorder()
+		g.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		if rec.Code != http.StatusInternalServerError || strings.Contains(rec.Body.String(), "before") {
+			t.Errorf("expected %s to fail without sending a partial page, got %d %q", url, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	g := NewServer()
+	_ = g.Get("/funcs/users/:id/books/:book", func(ctx *Ctx) error { return nil })
+	if err := g.NameRoute("funcs-book", "/funcs/users/:id/books/:book"); err != nil {
+		t.Fatal(err)
+	}
+	keepTemplateConfig(t)
+	engine.Config.StaticPrefix = "/static"
+
+	writeTemplates(t, map[string]string{
+		"funcs/url.html":           `<a href="{{url "funcs-book" "id" 42 "book" 7 "page" 2}}">`,
+		"funcs/asset.html":         `{{asset "css/app.css"}}`,
+		"funcs/csrf.html":          `<form>{{csrfField}}</form>`,
+		"funcs/json.html":          `<script>var data = {{json .}};</script>`,
+		"funcs/safe.html":          `{{safeHTML "<b>hi</b>"}}`,
+		"funcs/date.html":          `{{date "2006-01-02" .}}`,
+		"funcs/dict.html":          `{{template "partials/funcs-card.html" dict "Title" "card" "Body" .}}`,
+		"funcs/custom.html":        `{{shout .}}`,
+		"partials/funcs-card.html": `<h2>{{.Title}}</h2><p>{{.Body}}</p>`,
+	})
+	err := g.TemplateFuncs(template.FuncMap{"shout": strings.ToUpper})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name         string
+		page         string
+		data         any
+		expectedBody string
+	}{
+		{"url", "funcs/url.html", nil, `<a href="/funcs/users/42/books/7?page=2">`},
+		{"asset", "funcs/asset.html", nil, `/static/css/app.css`},
+		{"csrfField", "funcs/csrf.html", nil, `<form><input type="hidden" name="csrf_token" value="t&lt;k&gt;"></form>`},
+		{"json", "funcs/json.html", map[string]int{"a": 1}, `<script>var data = {"a":1};</script>`},
+		{"safeHTML", "funcs/safe.html", nil, `<b>hi</b>`},
+		{"date", "funcs/date.html", time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC), `2024-03-09`},
+		{"dict", "funcs/dict.html", "body", `<h2>card</h2><p>body</p>`},
+		{"custom", "funcs/custom.html", "hey", `HEY`},
+	}
+
+	failedCases := make(map[int]string, 0)
+	for i, c := range testCases {
+		route := "/funcs/render/" + c.name
+		_ = g.Get(route, func(ctx *Ctx) error {
+			ctx.Set(CSRFTokenKey, "t<k>")
+			return ctx.Render(c.page, c.data)
+		})
+
+		rec := httptest.NewRecorder()
+		g.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, route, nil))
+		if rec.Body.String() != c.expectedBody {
+			failedCases[i] = c.name
+			t.Errorf("FAILED [%d] - %s: got %q", i, c.name, rec.Body.String())
+		} else {
+			t.Logf("PASSED [%d] - %s\n", i, c.name)
+		}
+	}
+
+	t.Logf("TOTAL CASES: %d\n", len(testCases))
+	t.Logf("FAILED CASES: %d\n", len(failedCases))
+}
+
+func TestCSRFField(t *testing.T) {
+	g := NewServer()
+	writeTemplates(t, map[string]string{
+		"csrf/form.html":          `<form>{{csrfField}}<a href="/?q={{.}}">{{.}}</a></form>`,
+		"csrf/nested.html":        `{{if .}}{{range .}}{{template "partials/csrf-form.html" .}}{{end}}{{end}}`,
+		"partials/csrf-form.html": `<form>{{with .}}{{csrfField}}{{end}}</form>`,
+	})
+	if err := engine.parseTemplates(); err != nil {
+		t.Fatal(err)
+	}
+
+	field := `<input type="hidden" name="csrf_token" value="secret">`
+	testCases := []struct {
+		name         string
+		page         string
+		token        bool
+		funcs        bool // funcs renders with TemplateWithFuncs instead of Render
+		data         any
+		expectedBody string
+	}{
+		{"Token", "csrf/form.html", true, false, "x", `<form>` + field + `<a href="/?q=x">x</a></form>`},
+		{"No token", "csrf/form.html", false, false, "x", `<form><a href="/?q=x">x</a></form>`},
+		{"User data isn't replaced", "csrf/form.html", true, false, "goster-csrf-field-placeholder",
+			`<form>` + field + `<a href="/?q=goster-csrf-field-placeholder">goster-csrf-field-placeholder</a></form>`},
+		{"Nested in a partial", "csrf/nested.html", true, false, []string{"a", "b"}, `<form>` + field + `</form><form>` + field + `</form>`},
+		{"With funcs", "csrf/form.html", true, true, "x", `<form>` + field + `<a href="/?q=x">x</a></form>`},
+	}
+
+	failedCases := make(map[int]string, 0)
+	for i, c := range testCases {
+		route := fmt.Sprintf("/csrf-field/%d", i)
+		_ = g.Get(route, func(ctx *Ctx) error {
+			if c.token {
+				ctx.Set(CSRFTokenKey, "secret")
+			}
+			if c.funcs {
+				return ctx.TemplateWithFuncs(c.page, c.data, template.FuncMap{})
+			}
+			return ctx.Render(c.page, c.data)
+		})
+
+		// render twice, since the cached sets can't be cloned once they have been executed
+		for n := 0; n < 2; n++ {
+			rec := httptest.NewRecorder()
+			g.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, route, nil))
+			if rec.Body.String() != c.expectedBody {
+				failedCases[i] = c.name
+				t.Errorf("FAILED [%d] - %s: got %q", i, c.name, rec.Body.String())
+				break
+			}
+		}
+		if _, failed := failedCases[i]; !failed {
+			t.Logf("PASSED [%d] - %s\n", i, c.name)
+		}
+	}
+
+	t.Logf("TOTAL CASES: %d\n", len(testCases))
+	t.Logf("FAILED CASES: %d\n", len(failedCases))
+}
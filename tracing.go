@@ -0,0 +1,389 @@
+package goster
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	mathrand "math/rand"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Headers of the W3C Trace Context specification
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+// maxTracestateLength is the length above which an incoming tracestate is dropped, as allowed by the specification
+const maxTracestateLength = 512
+
+// ErrInvalidTraceparent is returned by ParseTraceparent for headers that don't follow the W3C Trace Context format.
+var ErrInvalidTraceparent = errors.New("goster: invalid traceparent")
+
+// TraceID identifies a trace, i.e. all the spans of a request across services.
+type TraceID [16]byte
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (s SpanID) String() string  { return hex.EncodeToString(s[:]) }
+
+// IsValid reports whether t isn't all zeros, which the specification forbids.
+func (t TraceID) IsValid() bool { return t != TraceID{} }
+
+// IsValid reports whether s isn't all zeros, which the specification forbids.
+func (s SpanID) IsValid() bool { return s != SpanID{} }
+
+func (t TraceID) MarshalText() ([]byte, error) { return []byte(t.String()), nil }
+func (s SpanID) MarshalText() ([]byte, error)  { return []byte(s.String()), nil }
+
+// SpanContext is what's propagated between services: the trace, the span that made the request and whether it's sampled.
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Sampled    bool
+	TraceState string // TraceState holds the vendor specific data of the tracestate header, passed on as it is
+}
+
+// IsValid reports whether sc has a valid trace and span ID.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Traceparent returns sc in the format of the traceparent header, like "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01".
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// ParseTraceparent parses the value of a traceparent header. Versions other than 00 are parsed as far as
+// the specification allows, i.e. their first four fields.
+func ParseTraceparent(h string) (sc SpanContext, err error) {
+	h = strings.TrimSpace(h)
+	if len(h) < 55 || h[2] != '-' || h[35] != '-' || h[52] != '-' {
+		return sc, ErrInvalidTraceparent
+	}
+
+	version, err := hex.DecodeString(h[:2])
+	if err != nil || version[0] == 0xff || (version[0] == 0 && len(h) != 55) || (len(h) > 55 && h[55] != '-') {
+		return sc, ErrInvalidTraceparent
+	}
+	if !isLowerHex(h[:2]) || !isLowerHex(h[3:35]) || !isLowerHex(h[36:52]) || !isLowerHex(h[53:55]) {
+		return sc, ErrInvalidTraceparent
+	}
+
+	hex.Decode(sc.TraceID[:], []byte(h[3:35]))
+	hex.Decode(sc.SpanID[:], []byte(h[36:52]))
+	flags, _ := hex.DecodeString(h[53:55])
+	sc.Sampled = flags[0]&0x01 == 1
+
+	if !sc.IsValid() {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	return sc, nil
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !('0' <= s[i] && s[i] <= '9' || 'a' <= s[i] && s[i] <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// SpanData is a finished span, as it's handed to a SpanExporter.
+type SpanData struct {
+	Name         string         `json:"name"`
+	TraceID      TraceID        `json:"trace_id"`
+	SpanID       SpanID         `json:"span_id"`
+	ParentSpanID SpanID         `json:"parent_span_id"` // ParentSpanID is all zeros for the root span of a trace
+	Start        time.Time      `json:"start"`
+	End          time.Time      `json:"end"`
+	Attributes   map[string]any `json:"attributes,omitempty"`
+	Error        string         `json:"error,omitempty"` // Error describes why the span failed, empty if it didn't
+}
+
+// Duration returns how long the span took.
+func (d SpanData) Duration() time.Duration {
+	return d.End.Sub(d.Start)
+}
+
+// SpanExporter sends finished spans to a tracing backend. ExportSpan is called from the goroutine that ended the span,
+// so implementations that talk to the network should hand the span off instead of blocking.
+type SpanExporter interface {
+	ExportSpan(span SpanData)
+}
+
+// Span is an operation within a trace, like the handling of a request. It's safe for concurrent use.
+type Span struct {
+	mu       sync.Mutex
+	data     SpanData
+	sc       SpanContext
+	exporter SpanExporter // exporter is nil if the span isn't sampled
+	ended    bool
+}
+
+// SpanContext returns the context that identifies the span, to be propagated to other services.
+func (s *Span) SpanContext() SpanContext {
+	return s.sc
+}
+
+// SetAttribute records key and value on the span, replacing any value recorded under key before.
+func (s *Span) SetAttribute(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.data.Attributes == nil {
+		s.data.Attributes = make(map[string]any)
+	}
+	s.data.Attributes[key] = value
+}
+
+// SetError marks the span as failed because of err.
+func (s *Span) SetError(err error) {
+	if err == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Error = err.Error()
+}
+
+// End ends the span and exports it if it's sampled. Only the first call has any effect.
+func (s *Span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	if s.exporter != nil {
+		s.exporter.ExportSpan(data)
+	}
+}
+
+// newSpan starts a span named name. It's a child of parent if parent is valid, otherwise it's the root of a new trace.
+func newSpan(name string, parent SpanContext, sampled bool, exporter SpanExporter) *Span {
+	s := &Span{data: SpanData{Name: name, Start: time.Now()}}
+	s.sc = SpanContext{TraceID: parent.TraceID, Sampled: sampled, TraceState: parent.TraceState}
+	if !s.sc.TraceID.IsValid() {
+		s.sc.TraceID = newTraceID()
+	}
+	s.sc.SpanID = newSpanID()
+	if sampled {
+		s.exporter = exporter
+	}
+
+	s.data.TraceID = s.sc.TraceID
+	s.data.SpanID = s.sc.SpanID
+	s.data.ParentSpanID = parent.SpanID
+	return s
+}
+
+func newTraceID() (t TraceID) {
+	for !t.IsValid() {
+		rand.Read(t[:])
+	}
+	return
+}
+
+func newSpanID() (s SpanID) {
+	for !s.IsValid() {
+		rand.Read(s[:])
+	}
+	return
+}
+
+// spanKey is the key of the current span in a context.Context
+type spanKey struct{}
+
+// ContextWithSpan returns a copy of ctx that carries span.
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the span carried by ctx, or nil if there's none.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// StartSpan starts a child of the span carried by ctx, e.g. to time a database query within a request, and returns
+// a context that carries it. The child is exported like its parent. If ctx carries no span, the span isn't exported.
+// End the span once the operation is done:
+//
+//	c, span := goster.StartSpan(ctx.Context(), "load user")
+//	defer span.End()
+//	user, err := db.LoadUser(c, id)
+func StartSpan(ctx context.Context, name string) (context.Context, *Span) {
+	var span *Span
+	if parent := SpanFromContext(ctx); parent != nil {
+		span = newSpan(name, parent.sc, parent.sc.Sampled, parent.exporter)
+	} else {
+		span = newSpan(name, SpanContext{}, false, nil)
+	}
+
+	return ContextWithSpan(ctx, span), span
+}
+
+// InjectTraceContext sets the traceparent and tracestate headers of h from the span carried by ctx, so that an outgoing
+// request continues the trace:
+//
+//	req, _ := http.NewRequestWithContext(ctx.Context(), http.MethodGet, url, nil)
+//	goster.InjectTraceContext(ctx.Context(), req.Header)
+func InjectTraceContext(ctx context.Context, h http.Header) {
+	span := SpanFromContext(ctx)
+	if span == nil {
+		return
+	}
+
+	h.Set(TraceparentHeader, span.sc.Traceparent())
+	if span.sc.TraceState != "" {
+		h.Set(TracestateHeader, span.sc.TraceState)
+	}
+}
+
+// TracingOptions configure the Tracing middleware.
+type TracingOptions struct {
+	Exporter   SpanExporter // Exporter receives the finished spans, a JSONExporter writing to stdout if nil
+	SampleRate float64      // SampleRate is the fraction of new traces that are sampled (exported), e.g. 0.1 for one in ten. 0 samples all of them. Requests that continue a trace follow the decision of the caller
+}
+
+// Tracing returns a middleware that creates a span for every request, following the W3C Trace Context specification.
+// If the request has a valid traceparent header, the span continues that trace, otherwise it starts a new one.
+//
+//	g.UseGlobal(goster.Tracing(goster.TracingOptions{Exporter: myExporter}))
+//
+// The span is named after the method and route pattern (e.g. "GET /users/:id") and records them along with the path,
+// the status code and the user agent. Responses with a 5xx status mark it as failed. Handlers get it with
+// SpanFromContext(ctx.Context()), start child spans with StartSpan and pass the trace on with InjectTraceContext.
+// The traceparent of the span is sent back in the response, and Ctx.Logger adds the "trace_id" and "span_id" attributes.
+//
+// Register it before any other middleware, so that the time spent in them is included.
+func Tracing(opts ...TracingOptions) RequestHandler {
+	var options TracingOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	if options.Exporter == nil {
+		options.Exporter = NewJSONExporter(os.Stdout)
+	}
+
+	return func(ctx *Ctx) error {
+		parent, err := ParseTraceparent(ctx.Request.Header.Get(TraceparentHeader))
+		sampled := parent.Sampled
+		if err == nil {
+			if ts := ctx.Request.Header.Get(TracestateHeader); len(ts) <= maxTracestateLength {
+				parent.TraceState = ts
+			}
+		} else {
+			sampled = options.SampleRate <= 0 || options.SampleRate >= 1 || mathrand.Float64() < options.SampleRate
+		}
+
+		name := ctx.Request.Method
+		if ctx.Route() != "" {
+			name += " " + ctx.Route()
+		}
+		span := newSpan(name, parent, sampled, options.Exporter)
+		span.data.Start = ctx.Response.start
+		span.SetAttribute("http.request.method", ctx.Request.Method)
+		span.SetAttribute("url.path", ctx.Request.URL.Path)
+		if ctx.Route() != "" {
+			span.SetAttribute("http.route", ctx.Route())
+		}
+		if ua := ctx.Request.UserAgent(); ua != "" {
+			span.SetAttribute("user_agent.original", ua)
+		}
+
+		ctx.span = span
+		ctx.logger = nil
+		ctx.SetContext(ContextWithSpan(ctx.Context(), span))
+		ctx.Response.Header().Set(TraceparentHeader, span.sc.Traceparent())
+
+		ctx.Next()
+
+		status := ctx.Response.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttribute("http.response.status_code", status)
+		if status >= http.StatusInternalServerError {
+			span.SetError(errors.New(http.StatusText(status)))
+		}
+		span.End()
+		return nil
+	}
+}
+
+// JSONExporter writes every span as a JSON object on its own line. It's safe for concurrent use.
+type JSONExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONExporter creates a JSONExporter writing to w, like os.Stdout or a RotatingFile.
+func NewJSONExporter(w io.Writer) *JSONExporter {
+	return &JSONExporter{w: w}
+}
+
+func (e *JSONExporter) ExportSpan(span SpanData) {
+	b, err := json.Marshal(struct {
+		SpanData
+		DurationMS float64 `json:"duration_ms"`
+	}{span, float64(span.Duration().Microseconds()) / 1000})
+	if err != nil {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.w.Write(append(b, '\n'))
+}
+
+// InMemoryExporter keeps the spans it's given, which makes it handy in tests. It's safe for concurrent use.
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+// NewInMemoryExporter creates an empty InMemoryExporter.
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{}
+}
+
+func (e *InMemoryExporter) ExportSpan(span SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, span)
+}
+
+// Spans returns the spans exported so far, in the order they ended.
+func (e *InMemoryExporter) Spans() []SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]SpanData(nil), e.spans...)
+}
+
+// Reset forgets the spans exported so far.
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = nil
+}
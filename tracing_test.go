@@ -0,0 +1,169 @@
+package goster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type TraceparentCase struct {
+	name     string
+	header   string
+	valid    bool
+	expected string // expected is the traceparent the parsed context formats to
+}
+
+func TestParseTraceparent(t *testing.T) {
+	testCases := []TraceparentCase{
+		{"Sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{"Not sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"},
+		{"Other flags", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-03", true, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{"Future version", "cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-what-the-future-holds", true, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{"Version 00 with extra fields", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false, ""},
+		{"Invalid version", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, ""},
+		{"Zero trace ID", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, ""},
+		{"Zero span ID", "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false, ""},
+		{"Uppercase", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false, ""},
+		{"Too short", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", false, ""},
+		{"Empty", "", false, ""},
+	}
+
+	failedCases := make(map[int]TraceparentCase, 0)
+	for i, c := range testCases {
+		sc, err := ParseTraceparent(c.header)
+		failed := (err == nil) != c.valid
+		if c.valid && sc.Traceparent() != c.expected {
+			failed = true
+		}
+
+		if failed {
+			failedCases[i] = c
+			t.Errorf("FAILED [%d] - %s: got %q (%v)", i, c.name, sc.Traceparent(), err)
+		} else {
+			t.Logf("PASSED [%d] - %s\n", i, c.name)
+		}
+	}
+
+	t.Logf("TOTAL CASES: %d\n", len(testCases))
+	t.Logf("FAILED CASES: %d\n", len(failedCases))
+}
+
+func TestTracing(t *testing.T) {
+	g := NewServer()
+	exporter := NewInMemoryExporter()
+
+	var outgoing http.Header
+	_ = g.Get("/tracing/users/:id", func(ctx *Ctx) error {
+		c, span := StartSpan(ctx.Context(), "load user")
+		span.SetAttribute("user.id", "42")
+		span.End()
+
+		outgoing = http.Header{}
+		InjectTraceContext(c, outgoing)
+		return nil
+	})
+	_ = g.Get("/tracing/fail", func(ctx *Ctx) error {
+		return errors.New("boom")
+	})
+	g.Use("/tracing/users/:id", Tracing(TracingOptions{Exporter: exporter}))
+	g.Use("/tracing/fail", Tracing(TracingOptions{Exporter: exporter}))
+
+	// continuing a trace
+	req := httptest.NewRequest(http.MethodGet, "/tracing/users/42", nil)
+	req.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set(TracestateHeader, "vendor=abc")
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+
+	spans := exporter.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("expected the request span and its child, got %v", spans)
+	}
+	child, server := spans[0], spans[1]
+	if server.Name != "GET /tracing/users/:id" || server.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || server.ParentSpanID.String() != "00f067aa0ba902b7" {
+		t.Errorf("expected the span to continue the incoming trace, got %+v", server)
+	}
+	if server.Attributes["http.route"] != "/tracing/users/:id" || server.Attributes["http.response.status_code"] != 200 || server.Error != "" {
+		t.Errorf("unexpected attributes %+v", server)
+	}
+	if child.Name != "load user" || child.TraceID != server.TraceID || child.ParentSpanID != server.SpanID || child.Attributes["user.id"] != "42" {
+		t.Errorf("expected a child of the request span, got %+v", child)
+	}
+	if outgoing.Get(TraceparentHeader) != "00-4bf92f3577b34da6a3ce929d0e0e4736-"+child.SpanID.String()+"-01" || outgoing.Get(TracestateHeader) != "vendor=abc" {
+		t.Errorf("expected the trace to be injected into outgoing headers, got %v", outgoing)
+	}
+	if rec.Header().Get(TraceparentHeader) != "00-4bf92f3577b34da6a3ce929d0e0e4736-"+server.SpanID.String()+"-01" {
+		t.Errorf("expected the traceparent of the span in the response, got %q", rec.Header().Get(TraceparentHeader))
+	}
+
+	// starting a new trace, with a failing handler
+	exporter.Reset()
+	req = httptest.NewRequest(http.MethodGet, "/tracing/fail", nil)
+	req.Header.Set(TraceparentHeader, "garbage")
+	g.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans = exporter.Spans()
+	if len(spans) != 1 || spans[0].ParentSpanID.IsValid() || spans[0].TraceID.String() == "4bf92f3577b34da6a3ce929d0e0e4736" || spans[0].Error == "" {
+		t.Errorf("expected a failed root span, got %+v", spans)
+	}
+}
+
+func TestTracingSampling(t *testing.T) {
+	g := NewServer()
+	exporter := NewInMemoryExporter()
+	_ = g.Get("/tracing-sampling", func(ctx *Ctx) error {
+		return nil
+	})
+	g.Use("/tracing-sampling", Tracing(TracingOptions{Exporter: exporter, SampleRate: 1e-12}))
+
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tracing-sampling", nil))
+	if len(exporter.Spans()) != 0 || !strings.HasSuffix(rec.Header().Get(TraceparentHeader), "-00") {
+		t.Errorf("expected an unsampled trace, got %v and %q", exporter.Spans(), rec.Header().Get(TraceparentHeader))
+	}
+
+	// the decision of the caller wins
+	req := httptest.NewRequest(http.MethodGet, "/tracing-sampling", nil)
+	req.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	g.ServeHTTP(httptest.NewRecorder(), req)
+	if len(exporter.Spans()) != 1 {
+		t.Errorf("expected the sampled trace of the caller to be exported, got %v", exporter.Spans())
+	}
+}
+
+func TestTracingLogsAndJSONExporter(t *testing.T) {
+	g := NewServer()
+	logger := g.Logger
+	defer func() { g.Logger = logger }()
+
+	var logs, spans bytes.Buffer
+	g.Logger = NewLogger(&logs, LogFormatJSON, slog.LevelInfo)
+
+	_ = g.Get("/tracing-logs", func(ctx *Ctx) error {
+		ctx.Logger().Info("traced")
+		return nil
+	})
+	g.Use("/tracing-logs", Tracing(TracingOptions{Exporter: NewJSONExporter(&spans)}))
+	g.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/tracing-logs", nil))
+
+	span := map[string]any{}
+	if err := json.Unmarshal(spans.Bytes(), &span); err != nil || span["name"] != "GET /tracing-logs" || span["duration_ms"] == nil {
+		t.Fatalf("expected a JSON span, got %q", spans.String())
+	}
+
+	rec := map[string]any{}
+	line, _, _ := strings.Cut(logs.String(), "\n")
+	if err := json.Unmarshal([]byte(line), &rec); err != nil || rec["trace_id"] != span["trace_id"] || rec["span_id"] != span["span_id"] {
+		t.Errorf("expected the log record to carry the trace, got %q", line)
+	}
+
+	if _, span := StartSpan(context.Background(), "orphan"); span.SpanContext().Sampled {
+		t.Errorf("expected spans without a parent not to be sampled")
+	}
+}
@@ -3,17 +3,47 @@ package goster
 import (
 	"fmt"
 	"mime"
+	"net/http"
 	"os"
-	"path"
 	"path/filepath"
 	"strings"
 )
 
-// Adds basic headers
-func DefaultHeader(c *Ctx) {
-	c.Response.Header().Set("Access-Control-Allow-Origin", "*")
-	c.Response.Header().Set("Connection", "Keep-Alive")
-	c.Response.Header().Set("Keep-Alive", "timeout=5, max=997")
+// Header values that are set on most responses. They're shared between all requests instead of allocating
+// a new slice every time, so they must only ever be replaced in a header and never modified in place.
+var (
+	textPlainValue   = []string{"text/plain"}
+	allowOriginValue = []string{"*"}
+	connectionValue  = []string{"Keep-Alive"}
+	keepAliveValue   = []string{"timeout=5, max=997"}
+)
+
+// DefaultHeader is a middleware that sets the headers Goster used to set on every response: `Access-Control-Allow-Origin: *`,
+// `Connection: Keep-Alive` and `Keep-Alive: timeout=5, max=997`. They're no longer sent unless it's registered:
+//
+//	g.UseGlobal(goster.DefaultHeader)
+//
+// Prefer CORS for the Access-Control headers. The keep-alive headers are ignored over HTTP/2, and net/http manages
+// persistent connections on its own.
+func DefaultHeader(c *Ctx) error {
+	h := c.Response.Header()
+	h["Access-Control-Allow-Origin"] = allowOriginValue
+	h["Connection"] = connectionValue
+	h["Keep-Alive"] = keepAliveValue
+	return nil
+}
+
+// addVary adds the header `name` to the Vary header of h, unless it's already listed.
+func addVary(h http.Header, name string) {
+	for _, v := range h.Values("Vary") {
+		for _, listed := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(listed), name) {
+				return
+			}
+		}
+	}
+
+	h.Add("Vary", name)
 }
 
 // cleanPath sanatizes a URL path. It removes suffix '/' if any and adds prefix '/' if missing. If the URL contains Query Parameters or Anchors,
@@ -48,35 +78,8 @@ func matchesDynamicRoute(urlPath string, routePath string) (isDynamic bool) {
 	cleanPath(&urlPath)
 	cleanPath(&routePath)
 
-	_, isDynamic = matchDynamicPath(urlPath, routePath)
-	return
-}
-
-func matchDynamicPath(urlPath, routePath string) (dp []DynamicPath, isDynamic bool) {
-	routePathSlice := strings.Split(routePath, "/")
-	urlSlice := strings.Split(urlPath, "/")
-
-	if len(routePathSlice) != len(urlSlice) {
-		return nil, false
-	}
-
-	hasDynamic := false
-	dp = []DynamicPath{}
-	for i, seg := range routePathSlice {
-		if strings.HasPrefix(seg, ":") {
-			hasDynamic = true
-			dynamicValue := strings.Split(urlSlice[i], "?")[0]
-			dp = append(dp, DynamicPath{
-				path:  strings.TrimPrefix(seg, ":"),
-				value: dynamicValue,
-			})
-		} else if seg != urlSlice[i] {
-			// static segment doesn't match
-			return nil, false
-		}
-	}
-
-	return dp, hasDynamic
+	var p Path
+	return p.match(urlPath, routePath) && len(p) > 0
 }
 
 func getContentType(filename string) string {
@@ -88,20 +91,30 @@ func getContentType(filename string) string {
 	return contentType
 }
 
+// resolveAppPath returns the absolute path of the directory dir. Relative paths are looked up in the working directory
+// first and then in the directory of the executable (which is a temporary directory under `go run`). For compatibility,
+// absolute paths that don't exist (like "/static") are looked up the same way. If dir isn't found an error is returned.
 func resolveAppPath(dir string) (string, error) {
-	fileDir, err := filepath.Abs(filepath.Dir(os.Args[0]))
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "cannot determine working directory for static dir %s\n", dir)
-		return dir, err
+	candidates := []string{}
+	if filepath.IsAbs(dir) {
+		candidates = append(candidates, dir)
 	}
 
-	// construct the full path to the static directory
-	return path.Join(fileDir, dir), nil
-}
+	relDir := strings.TrimLeft(filepath.FromSlash(dir), string(filepath.Separator))
+	if wd, err := os.Getwd(); err == nil {
+		candidates = append(candidates, filepath.Join(wd, relDir))
+	}
+	if exe, err := os.Executable(); err == nil {
+		candidates = append(candidates, filepath.Join(filepath.Dir(exe), relDir))
+	}
+
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+			return candidate, nil
+		}
+	}
 
-func pathExists(path string) (exists bool) {
-	_, err := os.Stat(path)
-	return err == nil
+	return "", fmt.Errorf("directory `%s` doesn't exist", dir)
 }
 
 // func cleanEmptyBytes(b *[]byte) {
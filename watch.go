@@ -0,0 +1,97 @@
+package goster
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// watcher reports changes to the files of a directory and its subdirectories.
+type watcher interface {
+	// changed reports whether anything in the directory changed since the last call.
+	changed() bool
+	// close releases the resources of the watcher.
+	close() error
+}
+
+// pollInterval is the minimum time between two scans of a polled directory
+var pollInterval = 500 * time.Millisecond
+
+// fileState is what pollWatcher compares to find out whether a file changed
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// pollWatcher finds changes by scanning the directory and comparing the modification time and size of every file with
+// the previous scan. Scans happen on demand, when changed is called, and at most once every pollInterval.
+type pollWatcher struct {
+	mu       sync.Mutex
+	dir      string
+	lastScan time.Time
+	files    map[string]fileState
+}
+
+// newPollWatcher creates a pollWatcher for dir and takes the first snapshot of it.
+func newPollWatcher(dir string) *pollWatcher {
+	w := &pollWatcher{dir: dir}
+	w.files = w.scan()
+	w.lastScan = time.Now()
+	return w
+}
+
+func (w *pollWatcher) changed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if time.Since(w.lastScan) < pollInterval {
+		return false
+	}
+	w.lastScan = time.Now()
+
+	files := w.scan()
+	changed := len(files) != len(w.files)
+	for path, state := range files {
+		if changed {
+			break
+		}
+		prev, exists := w.files[path]
+		changed = !exists || !prev.modTime.Equal(state.modTime) || prev.size != state.size
+	}
+
+	w.files = files
+	return changed
+}
+
+// scan records the state of every file in the directory
+func (w *pollWatcher) scan() map[string]fileState {
+	files := make(map[string]fileState)
+	_ = filepath.WalkDir(w.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			files[path] = fileState{modTime: info.ModTime(), size: info.Size()}
+		}
+		return nil
+	})
+
+	return files
+}
+
+func (w *pollWatcher) close() error {
+	return nil
+}
+
+// dirsUnder returns dir and all of its subdirectories
+func dirsUnder(dir string) (dirs []string) {
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+
+	return
+}
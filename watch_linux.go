@@ -0,0 +1,84 @@
+//go:build linux
+
+package goster
+
+import (
+	"sync"
+	"syscall"
+)
+
+// inotifyEvents are the inotify events that count as a change of the watched directory
+const inotifyEvents = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MODIFY | syscall.IN_CLOSE_WRITE |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_DELETE_SELF | syscall.IN_ATTRIB
+
+// inotifyWatcher finds changes through inotify. Its file descriptor is non-blocking and gets drained
+// whenever changed is called, so no goroutine is needed.
+type inotifyWatcher struct {
+	mu  sync.Mutex
+	fd  int
+	dir string
+	buf []byte
+}
+
+// newWatcher creates a watcher for dir. On Linux inotify is used, falling back to polling if it isn't available.
+func newWatcher(dir string) watcher {
+	w, err := newInotifyWatcher(dir)
+	if err != nil {
+		return newPollWatcher(dir)
+	}
+
+	return w
+}
+
+func newInotifyWatcher(dir string) (*inotifyWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_NONBLOCK | syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &inotifyWatcher{fd: fd, dir: dir, buf: make([]byte, 4096)}
+	err = w.watchDirs()
+	if err != nil {
+		_ = w.close()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// watchDirs adds a watch for the directory and every subdirectory of it. Directories that are already watched are left as they are.
+func (w *inotifyWatcher) watchDirs() error {
+	for _, dir := range dirsUnder(w.dir) {
+		_, err := syscall.InotifyAddWatch(w.fd, dir, inotifyEvents)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *inotifyWatcher) changed() (changed bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for {
+		// the descriptor is non-blocking, so reading stops with EAGAIN once there are no more events
+		n, err := syscall.Read(w.fd, w.buf)
+		if n <= 0 || err != nil {
+			break
+		}
+		changed = true
+	}
+
+	// new directories have to be watched too
+	if changed {
+		_ = w.watchDirs()
+	}
+
+	return
+}
+
+func (w *inotifyWatcher) close() error {
+	return syscall.Close(w.fd)
+}
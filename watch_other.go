@@ -0,0 +1,8 @@
+//go:build !linux
+
+package goster
+
+// newWatcher creates a watcher for dir. Outside of Linux directories are polled for changes.
+func newWatcher(dir string) watcher {
+	return newPollWatcher(dir)
+}
@@ -0,0 +1,96 @@
+package goster
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPollWatcher(t *testing.T) {
+	interval := pollInterval
+	pollInterval = 0
+	defer func() { pollInterval = interval }()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "index.html")
+	if err := os.WriteFile(file, []byte("v1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	w := newPollWatcher(dir)
+	if w.changed() {
+		t.Errorf("expected no changes right after watching")
+	}
+
+	if err := os.WriteFile(file, []byte("version 2"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if !w.changed() {
+		t.Errorf("expected an edited file to be reported")
+	}
+
+	if err := os.Mkdir(filepath.Join(dir, "partials"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "partials", "nav.html"), []byte("nav"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if !w.changed() {
+		t.Errorf("expected a new file to be reported")
+	}
+	if w.changed() {
+		t.Errorf("expected changes to be reported once")
+	}
+}
+
+func TestTemplateReload(t *testing.T) {
+	interval := pollInterval
+	pollInterval = 0
+	defer func() { pollInterval = interval }()
+
+	g := NewServer()
+	keepTemplateConfig(t)
+	dir := t.TempDir()
+	page := filepath.Join(dir, "page.html")
+	if err := os.WriteFile(page, []byte("v1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	engine.Config.BaseTemplateDir = dir
+	engine.Config.TemplatePaths = map[string]string{"page.html": page}
+	if err := engine.parseTemplates(); err != nil {
+		t.Fatal(err)
+	}
+
+	g.DevMode(true)
+	defer g.DevMode(false)
+
+	_ = g.Get("/reload/:page", func(ctx *Ctx) error {
+		name, _ := ctx.Path.Get("page")
+		return ctx.Render(name, nil)
+	})
+	render := func(name string) string {
+		rec := httptest.NewRecorder()
+		g.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/reload/"+name, nil))
+		return rec.Body.String()
+	}
+
+	if body := render("page.html"); body != "v1" {
+		t.Fatalf("expected `v1`, got %q", body)
+	}
+
+	if err := os.WriteFile(page, []byte("version 2"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "new.html"), []byte("new"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if body := render("page.html"); body != "version 2" {
+		t.Errorf("expected the edited template to be re-parsed, got %q", body)
+	}
+	if body := render("new.html"); body != "new" {
+		t.Errorf("expected the new template to be picked up, got %q", body)
+	}
+}